@@ -0,0 +1,51 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"expvar"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/glotchimo/recast/internal/bot"
+)
+
+func serveDebug(addr, token string, b *bot.Bot) *http.Server {
+	expvar.Publish("guilds", expvar.Func(func() any { return b.GuildStats() }))
+	expvar.Publish("goroutines", expvar.Func(func() any { return runtime.NumGoroutine() }))
+
+	var handler http.Handler = http.DefaultServeMux
+	if token != "" {
+		handler = requireToken(token, handler)
+	}
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		slog.Info("debug server listening", "addr", addr, "protected", token != "")
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("debug server stopped", "error", err)
+		}
+	}()
+
+	return srv
+}
+
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
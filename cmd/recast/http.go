@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/glotchimo/recast/internal/bot"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const readinessTimeout = 5 * time.Second
+
+// serveHTTP starts before the bot so liveness probes pass while it connects;
+// readiness stays unavailable until the bot is stored in current.
+func serveHTTP(addr string, current *atomic.Pointer[bot.Bot]) *http.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true, "version": VERSION})
+	})
+
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
+
+		b := current.Load()
+		if b == nil {
+			writeJSON(w, http.StatusServiceUnavailable, bot.Readiness{
+				Checks: []bot.Check{{Name: "started", OK: false, Detail: "starting"}},
+			})
+			return
+		}
+
+		readiness := b.Readiness(ctx)
+		status := http.StatusOK
+		if !readiness.Ready {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, readiness)
+	})
+
+	mux.Handle("GET /metrics", promhttp.Handler())
+
+	mux.HandleFunc("GET /shards", func(w http.ResponseWriter, r *http.Request) {
+		shards := []bot.ShardStatus{}
+		if b := current.Load(); b != nil {
+			shards = b.Shards()
+		}
+		writeJSON(w, http.StatusOK, shards)
+	})
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		slog.Info("http server listening", "addr", addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("http server stopped", "error", err)
+		}
+	}()
+
+	return srv
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("error writing http response", "error", err)
+	}
+}
@@ -1,30 +1,68 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
-	"os/signal"
-
-	_ "net/http/pprof"
+	"time"
 
 	"github.com/caarlos0/env/v11"
-	"github.com/glotchimo/recast/internal/bot"
 	"github.com/joho/godotenv"
 )
 
 var VERSION = "dev"
 
+const usage = `usage: recast <command> [arguments]
+
+commands:
+  serve [-skip-migrate]        run the bot (default)
+  migrate up [n]               apply all or the next n migrations
+  migrate down [n | -all]      roll back the last n migrations, or all of them
+  migrate goto <version>       migrate up or down to a version
+  migrate force <version>      set the version without running migrations, clearing the dirty flag
+  migrate version              print the current version
+  migrate create <name>        scaffold the next pair of up and down migrations
+`
+
 type Conf struct {
-	Debug       bool   `env:"DEBUG"`
-	Token       string `env:"BOT_TOKEN"`
-	Intents     int    `env:"BOT_INTENTS" envDefault:"32509"`
-	DatabaseURL string `env:"DATABASE_URL"`
-	CacheURL    string `env:"REDIS_URL"`
-	ShardID     int    `env:"SHARD_ID" envDefault:"0"`
-	ShardCount  int    `env:"SHARD_COUNT" envDefault:"1"`
+	Debug          bool   `env:"DEBUG"`
+	Token          string `env:"BOT_TOKEN"`
+	Intents        int    `env:"BOT_INTENTS" envDefault:"32509"`
+	DatabaseURL    string `env:"DATABASE_URL"`
+	MigrationsPath string `env:"MIGRATIONS_PATH"`
+	SkipMigrate    bool   `env:"SKIP_MIGRATE"`
+	CacheURL       string `env:"REDIS_URL"`
+	ShardID        int    `env:"SHARD_ID" envDefault:"0"`
+	ShardCount     int    `env:"SHARD_COUNT" envDefault:"1"`
+
+	Registration string `env:"COMMAND_REGISTRATION" envDefault:"guild"`
+	DevGuildID   string `env:"DEV_GUILD_ID"`
+
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"30s"`
+	HTTPAddr        string        `env:"HTTP_ADDR" envDefault:":8080"`
+	DebugAddr       string        `env:"DEBUG_ADDR"`
+	DebugToken      string        `env:"DEBUG_TOKEN"`
+
+	InteractionBatchSize     int           `env:"INTERACTION_BATCH_SIZE" envDefault:"100"`
+	InteractionFlushInterval time.Duration `env:"INTERACTION_FLUSH_INTERVAL" envDefault:"5s"`
+	InteractionBuffer        int           `env:"INTERACTION_BUFFER" envDefault:"10000"`
+
+	RetentionDays     int           `env:"RETENTION_DAYS" envDefault:"0"`
+	RetentionInterval time.Duration `env:"RETENTION_INTERVAL" envDefault:"1h"`
+	ArchiveDir        string        `env:"ARCHIVE_DIR" envDefault:"archive"`
+	RollupInterval    time.Duration `env:"ROLLUP_INTERVAL" envDefault:"1h"`
+
+	MessageCacheSize int           `env:"MESSAGE_CACHE_SIZE" envDefault:"1000"`
+	MessageCacheTTL  time.Duration `env:"MESSAGE_CACHE_TTL" envDefault:"24h"`
+
+	TraceExporter    string  `env:"TRACE_EXPORTER"`
+	TraceFile        string  `env:"TRACE_FILE" envDefault:"traces.jsonl"`
+	TraceSampleRatio float64 `env:"TRACE_SAMPLE_RATIO" envDefault:"1"`
 }
 
 func main() {
-	if err := godotenv.Load(); err != nil {
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
 		panic(err)
 	}
 
@@ -33,13 +71,26 @@ func main() {
 		panic(err)
 	}
 
-	bot, err := bot.NewBot(conf.Debug, conf.DatabaseURL, conf.CacheURL, conf.Token, conf.ShardID, conf.ShardCount, conf.Intents)
-	if err != nil {
-		panic(err)
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
 	}
-	defer bot.Close()
 
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt)
-	<-stop
+	var err error
+	switch command {
+	case "serve":
+		err = serve(conf, args)
+	case "migrate":
+		err = runMigrate(conf, args)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
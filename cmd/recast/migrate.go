@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/glotchimo/recast/internal/database"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/graxinc/errutil"
+)
+
+const defaultMigrationsPath = "migrations"
+
+var (
+	migrationFile = regexp.MustCompile(`^(\d+)_.+\.(up|down)\.sql$`)
+	migrationName = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+func runMigrate(conf Conf, args []string) error {
+	if len(args) == 0 {
+		return errors.New("migrate: missing subcommand\n\n" + usage)
+	}
+
+	command, args := args[0], args[1:]
+	if command == "create" {
+		if len(args) != 1 {
+			return errors.New("usage: recast migrate create <name>")
+		}
+		return createMigration(conf.MigrationsPath, args[0])
+	}
+
+	m, err := database.NewMigrate(conf.DatabaseURL, conf.MigrationsPath)
+	if err != nil {
+		return errutil.With(err)
+	}
+	defer m.Close()
+
+	switch command {
+	case "up":
+		n, err := optionalCount(args)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			err = m.Up()
+		} else {
+			err = m.Steps(n)
+		}
+		if err := ignoreNoChange(err); err != nil {
+			return errutil.With(err)
+		}
+
+	case "down":
+		if len(args) == 1 && args[0] == "-all" {
+			if err := ignoreNoChange(m.Down()); err != nil {
+				return errutil.With(err)
+			}
+			break
+		}
+		n, err := optionalCount(args)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			n = 1
+		}
+		if err := ignoreNoChange(m.Steps(-n)); err != nil {
+			return errutil.With(err)
+		}
+
+	case "goto":
+		v, err := requiredVersion(command, args)
+		if err != nil {
+			return err
+		}
+		if err := ignoreNoChange(m.Migrate(uint(v))); err != nil {
+			return errutil.With(err)
+		}
+
+	case "force":
+		v, err := requiredVersion(command, args)
+		if err != nil {
+			return err
+		}
+		if err := m.Force(v); err != nil {
+			return errutil.With(err)
+		}
+
+	case "version":
+
+	default:
+		return fmt.Errorf("migrate: unknown subcommand %q\n\n%s", command, usage)
+	}
+
+	version, dirty, err := m.Version()
+	switch {
+	case errors.Is(err, migrate.ErrNilVersion):
+		fmt.Println("no migrations applied")
+	case err != nil:
+		return errutil.With(err)
+	case dirty:
+		fmt.Printf("version %d (dirty)\n", version)
+	default:
+		fmt.Printf("version %d\n", version)
+	}
+
+	return nil
+}
+
+func createMigration(dir, name string) error {
+	if dir == "" {
+		dir = defaultMigrationsPath
+	}
+
+	name = strings.Trim(migrationName.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return errors.New("migrate: name must contain letters or numbers")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return errutil.With(err)
+	}
+
+	next := uint64(1)
+	for _, e := range entries {
+		match := migrationFile.FindStringSubmatch(e.Name())
+		if match == nil {
+			continue
+		}
+		n, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return errutil.With(err)
+		}
+		if n >= next {
+			next = n + 1
+		}
+	}
+
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%06d_%s.%s.sql", next, name, direction))
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err != nil {
+			return errutil.With(err)
+		}
+		if err := f.Close(); err != nil {
+			return errutil.With(err)
+		}
+		fmt.Println(path)
+	}
+
+	return nil
+}
+
+func optionalCount(args []string) (int, error) {
+	switch len(args) {
+	case 0:
+		return 0, nil
+	case 1:
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("migrate: invalid count %q", args[0])
+		}
+		return n, nil
+	}
+	return 0, errors.New("migrate: too many arguments")
+}
+
+func requiredVersion(command string, args []string) (int, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("usage: recast migrate %s <version>", command)
+	}
+
+	v, err := strconv.Atoi(args[0])
+	if err != nil || v < 0 || (command == "goto" && v == 0) {
+		return 0, fmt.Errorf("migrate: invalid version %q", args[0])
+	}
+
+	return v, nil
+}
+
+func ignoreNoChange(err error) error {
+	if errors.Is(err, migrate.ErrNoChange) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+
+	_ "net/http/pprof"
+
+	"github.com/glotchimo/recast/internal/bot"
+	"github.com/glotchimo/recast/internal/tracing"
+	"github.com/graxinc/errutil"
+)
+
+func serve(conf Conf, args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	flags.BoolVar(&conf.SkipMigrate, "skip-migrate", conf.SkipMigrate, "don't apply pending migrations on startup")
+	if err := flags.Parse(args); err != nil {
+		return errutil.With(err)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    conf.TraceExporter,
+		File:        conf.TraceFile,
+		SampleRatio: conf.TraceSampleRatio,
+		Version:     VERSION,
+	})
+	if err != nil {
+		return errutil.With(err)
+	}
+
+	var current atomic.Pointer[bot.Bot]
+	servers := []*http.Server{serveHTTP(conf.HTTPAddr, &current)}
+
+	bot, err := bot.NewBot(bot.Config{
+		Debug:          conf.Debug,
+		Token:          conf.Token,
+		Intents:        conf.Intents,
+		DatabaseURL:    conf.DatabaseURL,
+		MigrationsPath: conf.MigrationsPath,
+		SkipMigrate:    conf.SkipMigrate,
+		CacheURL:       conf.CacheURL,
+		ShardID:        conf.ShardID,
+		ShardCount:     conf.ShardCount,
+		Registration:   bot.RegistrationMode(conf.Registration),
+		DevGuildID:     conf.DevGuildID,
+
+		InteractionBatchSize:     conf.InteractionBatchSize,
+		InteractionFlushInterval: conf.InteractionFlushInterval,
+		InteractionBuffer:        conf.InteractionBuffer,
+
+		Retention: bot.Retention{
+			Days:       conf.RetentionDays,
+			Interval:   conf.RetentionInterval,
+			ArchiveDir: conf.ArchiveDir,
+		},
+		RollupInterval: conf.RollupInterval,
+
+		MessageCacheSize: conf.MessageCacheSize,
+		MessageCacheTTL:  conf.MessageCacheTTL,
+	})
+	if err != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), conf.ShutdownTimeout)
+		defer cancel()
+		shutdown(ctx, servers)
+		return errutil.With(err)
+	}
+	current.Store(bot)
+
+	if conf.DebugAddr != "" {
+		servers = append(servers, serveDebug(conf.DebugAddr, conf.DebugToken, bot))
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	sig := <-stop
+	slog.Info("received signal", "signal", sig)
+
+	ctx, cancel := context.WithTimeout(context.Background(), conf.ShutdownTimeout)
+	defer cancel()
+
+	err = bot.Close(ctx)
+	shutdown(ctx, servers)
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Warn("error flushing traces", "error", err)
+	}
+	if err != nil {
+		return errutil.With(err)
+	}
+
+	return nil
+}
+
+func shutdown(ctx context.Context, servers []*http.Server) {
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			slog.Warn("error shutting down http server", "addr", srv.Addr, "error", err)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package bot
+
+import (
+	"context"
+	"time"
+
+	"github.com/graxinc/errutil"
+)
+
+func (b *Bot) rollup() {
+	if b.conf.RollupInterval <= 0 || b.conf.ShardID != 0 {
+		return
+	}
+
+	ticker := time.NewTicker(b.conf.RollupInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := b.Rollup(b.ctx); err != nil {
+			b.l.Error("error rolling up usage", "error", err)
+		}
+
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (b *Bot) Rollup(ctx context.Context) error {
+	start := time.Now()
+	today := start.UTC().Truncate(24 * time.Hour)
+
+	last, err := b.d.LastRollup(ctx)
+	if err != nil {
+		return errutil.With(err)
+	}
+	if last.IsZero() {
+		return nil
+	}
+
+	var days int
+	for day := last.UTC().Truncate(24 * time.Hour); !day.After(today); day = day.AddDate(0, 0, 1) {
+		if err := b.d.RollupUsage(ctx, day); err != nil {
+			return errutil.With(err)
+		}
+		days++
+	}
+
+	b.l.Debug("rolled up usage", "days", days, "since", last, "duration", time.Since(start))
+	return nil
+}
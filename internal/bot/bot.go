@@ -2,15 +2,17 @@ package bot
 
 import (
 	"context"
-	"crypto/sha256"
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"os"
 	"runtime"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
@@ -19,138 +21,422 @@ import (
 	"github.com/glotchimo/recast/internal/database"
 	"github.com/glotchimo/recast/internal/handlers"
 	"github.com/glotchimo/recast/internal/handlers/commands"
+	"github.com/glotchimo/recast/internal/metrics"
 	"github.com/glotchimo/recast/internal/models"
 	"github.com/glotchimo/recast/internal/response"
+	"github.com/glotchimo/recast/internal/session"
+	"github.com/glotchimo/recast/internal/tracing"
 	"github.com/glotchimo/recast/internal/utils"
 	"github.com/graxinc/errutil"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-var lookup map[string]handlers.Handler = map[string]handlers.Handler{
-	"ping": &commands.Ping{},
-}
+var lookup = index(
+	&commands.Ping{},
+	&commands.Commands{},
+	&commands.Logs{},
+	&commands.Retention{},
+	&commands.Voice{},
+	&commands.VoiceHistory{},
+	&commands.Stats{},
+)
+
+const (
+	eventQueueSize   = 1000
+	messageQueueSize = 1000
+	voiceQueueSize   = 1000
+	relayQueueSize   = 500
+	flushTimeout     = 10 * time.Second
+)
 
 type EventType int
 
 const (
 	EventTypeGuildUpdate EventType = iota
 	EventTypeInteraction
+	EventTypeMsgCreation
+	EventTypeMsgUpdate
 	EventTypeMsgDeletion
 	EventTypeVoiceUpdate
 )
 
+func (t EventType) String() string {
+	switch t {
+	case EventTypeGuildUpdate:
+		return "guild_update"
+	case EventTypeInteraction:
+		return "interaction"
+	case EventTypeMsgCreation:
+		return "message_creation"
+	case EventTypeMsgUpdate:
+		return "message_update"
+	case EventTypeMsgDeletion:
+		return "message_deletion"
+	case EventTypeVoiceUpdate:
+		return "voice_update"
+	}
+	return "unknown"
+}
+
 type GuildEvent struct {
 	Type EventType
 
 	GuildUpdate *dg.GuildUpdate
 	Interaction *dg.InteractionCreate
+	MsgCreation *dg.MessageCreate
+	MsgUpdate   *dg.MessageUpdate
 	MsgDeletion *dg.MessageDelete
 	VoiceUpdate *dg.VoiceStateUpdate
+
+	ctx  context.Context
+	done func()
 }
 
 type GuildContext struct {
-	Context context.Context
-	Cancel  context.CancelFunc
-	Events  chan GuildEvent
-	Relay   chan string
+	Context    context.Context
+	Cancel     context.CancelFunc
+	Events     chan GuildEvent
+	Messages   chan GuildEvent
+	Voice      chan GuildEvent
+	Relay      chan string
+	Goroutines atomic.Int64
+}
+
+type store interface {
+	database.Store
+	Ping(ctx context.Context) error
+	GetBot(ctx context.Context, id string) (*models.Bot, error)
+	PutBot(ctx context.Context, bot models.Bot) error
+	PurgeInteractions(ctx context.Context, now time.Time, defaultDays, limit int, archive func([]models.ArchivedInteraction) error) (int, error)
+	RollupUsage(ctx context.Context, day time.Time) error
+	LastRollup(ctx context.Context) (time.Time, error)
+	TotalInteractions(ctx context.Context) (int, error)
+	OpenVoiceSession(ctx context.Context, guildID, userID string) (*models.VoiceSession, error)
+	OpenVoiceSessions(ctx context.Context, guildID string) ([]models.VoiceSession, error)
+	LastVoiceEvent(ctx context.Context, guildID string) (time.Time, error)
+	PurgeVoiceEvents(ctx context.Context, now time.Time, defaultDays, limit int) (int, error)
+	Close() error
 }
 
 type Bot struct {
 	mu     sync.RWMutex
 	ctx    context.Context
 	cancel context.CancelFunc
+	conf   Config
+
+	gw *dg.Session
+	s  session.Session
+	d  store
+	c  *cache.Cache
+	l  *slog.Logger
+	r  *response.Responder
+	w  *database.Writer
+
+	events     chan GuildEvent
+	contexts   map[string]*GuildContext
+	middleware []handlers.Middleware
+	owners     map[string]bool
+
+	closing  bool
+	inflight sync.WaitGroup
+	voices   sync.WaitGroup
+}
 
-	s *dg.Session
-	d *database.Database
-	c *cache.Cache
-	l *slog.Logger
-	r *response.Responder
-
-	events   chan GuildEvent
-	contexts map[string]*GuildContext
+type Config struct {
+	Debug          bool
+	Token          string
+	Intents        int
+	DatabaseURL    string
+	MigrationsPath string
+	SkipMigrate    bool
+	CacheURL       string
+	ShardID        int
+	ShardCount     int
+	Registration   RegistrationMode
+	DevGuildID     string
+
+	InteractionBatchSize     int
+	InteractionFlushInterval time.Duration
+	InteractionBuffer        int
+
+	Retention      Retention
+	RollupInterval time.Duration
+
+	MessageCacheSize int
+	MessageCacheTTL  time.Duration
 }
 
-func NewBot(debug bool, dbURL, cacheURL, token string, shardID, shardCount, intents int) (*Bot, error) {
-	b := Bot{
-		events:   make(chan GuildEvent),
-		contexts: make(map[string]*GuildContext),
+func NewBot(conf Config) (*Bot, error) {
+	switch conf.Registration {
+	case "":
+		conf.Registration = RegisterGuild
+	case RegisterGuild, RegisterGlobal:
+	case RegisterDev:
+		if conf.DevGuildID == "" {
+			return nil, fmt.Errorf("dev command registration requires a dev guild ID")
+		}
+	default:
+		return nil, fmt.Errorf("unknown command registration mode %q", conf.Registration)
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	b.ctx = ctx
-	b.cancel = cancel
-
-	if debug {
-		b.l = slog.Default()
-	} else {
-		b.l = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{AddSource: true}))
-	}
+	b := newBot(conf)
 
-	database, err := database.NewDatabase(b.l, dbURL)
+	database, err := database.NewDatabase(b.l, conf.DatabaseURL)
 	if err != nil {
 		return nil, errutil.With(err)
 	}
 	b.d = database
+	b.w = newInteractionWriter(b.l, database, conf)
+
+	if conf.SkipMigrate {
+		b.l.Info("skipping migrations")
+	} else if err := database.Migrate(conf.DatabaseURL, conf.MigrationsPath); err != nil {
+		return nil, errutil.With(err)
+	}
 
-	session, err := dg.New("Bot " + token)
+	gw, err := dg.New("Bot " + conf.Token)
 	if err != nil {
 		return nil, errutil.With(err)
 	}
-	b.s = session
+	b.gw = gw
+	b.s = session.Wrap(gw)
 
-	b.s.Identify.Intents = dg.Intent(intents)
+	b.gw.Identify.Intents = dg.Intent(conf.Intents)
 
-	b.s.ShardID = shardID
-	b.s.ShardCount = shardCount
-	b.l.Info("sharding enabled", "shard_id", shardID, "shard_count", shardCount)
+	b.gw.ShardID = conf.ShardID
+	b.gw.ShardCount = conf.ShardCount
+	b.l.Info("sharding enabled", "shard_id", conf.ShardID, "shard_count", conf.ShardCount)
 
-	cache, err := cache.NewCache(cacheURL, session, b.l, database)
+	cache, err := cache.NewCache(conf.CacheURL, gw, b.l, database)
 	if err != nil {
 		return nil, errutil.With(err)
 	}
 	b.c = cache
 
 	b.r = response.NewSessionResponder(b.s, b.l, b.d, b.ctx)
+	b.r.OnFail(b.relayFailure)
 
-	b.s.AddHandler(func(s *dg.Session, r *dg.Ready) {
+	b.gw.AddHandler(func(s *dg.Session, r *dg.Ready) {
 		b.l.Info("bot connected to gateway",
 			"bot", fmt.Sprintf("%s#%s", r.User.Username, r.User.Discriminator),
 			"guilds", len(s.State.Guilds),
 			"version", utils.GetCommit(),
-			"shard_id", shardID,
-			"shard_count", shardCount,
+			"shard_id", conf.ShardID,
+			"shard_count", conf.ShardCount,
+			"registration", conf.Registration,
 		)
+
+		b.identify()
+
+		if conf.Registration == RegisterGlobal {
+			go b.loadGlobal()
+		}
 	})
 
-	if err := b.s.Open(); err != nil {
+	if err := b.gw.Open(); err != nil {
 		return nil, errutil.With(err)
 	}
 
-	b.s.AddHandler(func(s *dg.Session, g *dg.GuildCreate) { b.register(g.Guild) })
-	b.s.AddHandler(func(s *dg.Session, g *dg.GuildDelete) { b.remove(g.Guild) })
+	b.gw.AddHandler(func(s *dg.Session, g *dg.GuildCreate) { b.register(g.Guild) })
+	b.gw.AddHandler(func(s *dg.Session, g *dg.GuildDelete) { b.remove(g.Guild) })
 
-	b.s.AddHandler(func(s *dg.Session, i *dg.InteractionCreate) {
+	b.gw.AddHandler(func(s *dg.Session, i *dg.InteractionCreate) {
 		b.enqueue(i.GuildID, GuildEvent{Type: EventTypeInteraction, Interaction: i})
 	})
-	b.s.AddHandler(func(s *dg.Session, d *dg.MessageDelete) {
-		b.enqueue(d.GuildID, GuildEvent{Type: EventTypeMsgDeletion, MsgDeletion: d})
-	})
-	b.s.AddHandler(func(s *dg.Session, v *dg.VoiceStateUpdate) {
+	if conf.MessageCacheSize > 0 {
+		b.gw.AddHandler(func(s *dg.Session, m *dg.MessageCreate) {
+			if m.GuildID != "" {
+				b.enqueue(m.GuildID, GuildEvent{Type: EventTypeMsgCreation, MsgCreation: m})
+			}
+		})
+		b.gw.AddHandler(func(s *dg.Session, m *dg.MessageUpdate) {
+			if m.GuildID != "" {
+				b.enqueue(m.GuildID, GuildEvent{Type: EventTypeMsgUpdate, MsgUpdate: m})
+			}
+		})
+		b.gw.AddHandler(func(s *dg.Session, d *dg.MessageDelete) {
+			if d.GuildID != "" {
+				b.enqueue(d.GuildID, GuildEvent{Type: EventTypeMsgDeletion, MsgDeletion: d})
+			}
+		})
+	}
+	b.gw.AddHandler(func(s *dg.Session, v *dg.VoiceStateUpdate) {
 		b.enqueue(v.GuildID, GuildEvent{Type: EventTypeVoiceUpdate, VoiceUpdate: v})
 	})
 
 	go b.route()
 	go b.status()
+	go b.retain()
+	go b.rollup()
+
+	return b, nil
+}
+
+func newBot(conf Config) *Bot {
+	b := &Bot{
+		conf:     conf,
+		events:   make(chan GuildEvent),
+		contexts: make(map[string]*GuildContext),
+		owners:   make(map[string]bool),
+	}
+
+	b.Use(
+		handlers.Trace(),
+		handlers.Log(),
+		handlers.Report(),
+		handlers.Persist(b.record),
+		handlers.Measure(),
+		handlers.Recover(),
+	)
+	// Audit runs after the checks so refused interactions are only relayed
+	// as failures, not as uses.
+	b.Use(handlers.Authorize(b.isOwner), handlers.Throttle(), handlers.Audit())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	b.ctx = ctx
+	b.cancel = cancel
+
+	if conf.Debug {
+		b.l = slog.Default()
+	} else {
+		b.l = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{AddSource: true}))
+	}
 
-	return &b, nil
+	return b
 }
 
-func (b *Bot) Close() {
-	defer b.s.Close()
-	defer b.d.Close()
-	defer b.c.Close()
+func (b *Bot) identify() {
+	app, err := b.gw.Application("@me")
+	if err != nil {
+		b.l.Error("error fetching application", "error", err)
+		return
+	}
+
+	owners := make(map[string]bool)
+	if app.Owner != nil {
+		owners[app.Owner.ID] = true
+	}
+	if app.Team != nil {
+		for _, m := range app.Team.Members {
+			if m.User != nil {
+				owners[m.User.ID] = true
+			}
+		}
+	}
+
+	b.mu.Lock()
+	b.owners = owners
+	b.mu.Unlock()
+
+	b.l.Info("application owners loaded", "owners", len(owners))
+}
+
+func newInteractionWriter(l *slog.Logger, q database.Querier, conf Config) *database.Writer {
+	return database.NewWriter(l, q, models.TableInteractions, database.WriterConfig{
+		BatchSize:     conf.InteractionBatchSize,
+		FlushInterval: conf.InteractionFlushInterval,
+		MaxBuffered:   conf.InteractionBuffer,
+	})
+}
+
+func (b *Bot) record(i models.Interaction) {
+	b.w.Write(i)
+}
+
+func (b *Bot) isOwner(userID string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.owners[userID]
+}
+
+func (b *Bot) Close(ctx context.Context) error {
+	b.mu.Lock()
+	b.closing = true
+	b.mu.Unlock()
+
+	b.l.Info("shutting down, waiting for in-flight handlers")
+
+	done := make(chan struct{})
+	go func() {
+		b.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		b.l.Info("in-flight handlers finished")
+	case <-ctx.Done():
+		b.l.Warn("shutdown deadline exceeded, cancelling in-flight handlers")
+	}
 
 	b.cancel()
-	close(b.events)
+
+	// Waiting on handlers can use up ctx, and the final writes still need
+	// time to land.
+	fctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), flushTimeout)
+	defer cancel()
+
+	var errs []error
+	if err := b.w.Close(fctx); err != nil {
+		errs = append(errs, errutil.With(err))
+	}
+	if b.gw != nil {
+		if err := b.gw.Close(); err != nil {
+			errs = append(errs, errutil.With(err))
+		}
+	}
+
+	// A voice update still being written could reopen a session after it's
+	// closed below.
+	voices := make(chan struct{})
+	go func() {
+		b.voices.Wait()
+		close(voices)
+	}()
+	select {
+	case <-voices:
+	case <-fctx.Done():
+		b.l.Warn("flush deadline exceeded waiting for voice updates")
+	}
+
+	b.mu.RLock()
+	guildIDs := slices.Collect(maps.Keys(b.contexts))
+	b.mu.RUnlock()
+
+	now := time.Now().UTC()
+	for _, guildID := range guildIDs {
+		if err := b.closeVoice(fctx, guildID, now); err != nil {
+			errs = append(errs, errutil.With(err))
+		}
+	}
+	if err := b.d.Close(); err != nil {
+		errs = append(errs, errutil.With(err))
+	}
+	if err := b.c.Close(); err != nil {
+		errs = append(errs, errutil.With(err))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (b *Bot) spawn(gc *GuildContext, fn func()) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closing {
+		b.l.Debug("dropped handler during shutdown")
+		return
+	}
+
+	b.inflight.Add(1)
+	gc.Goroutines.Add(1)
+	go func() {
+		defer b.inflight.Done()
+		defer gc.Goroutines.Add(-1)
+		fn()
+	}()
 }
 
 func (b *Bot) route() {
@@ -159,14 +445,6 @@ func (b *Bot) route() {
 		case <-b.ctx.Done():
 			return
 		case e := <-b.events:
-			if e.Interaction != nil {
-				if err := b.d.Create(b.ctx, models.Interaction{
-					Interaction: e.Interaction.Interaction,
-				}); err != nil {
-					b.l.Error("error storing interaction", "error", err)
-				}
-			}
-
 			switch e.Type {
 			case EventTypeGuildUpdate:
 				b.register(e.GuildUpdate.Guild)
@@ -200,7 +478,7 @@ func (b *Bot) status() {
 				msg = fmt.Sprintf("Helping %d servers", count)
 
 			case 1:
-				count, err := b.d.Count(b.ctx, models.TableInteractions, nil)
+				count, err := b.d.TotalInteractions(b.ctx)
 				if err != nil {
 					b.l.Error("error counting activities", "error", err)
 					continue
@@ -211,11 +489,11 @@ func (b *Bot) status() {
 				s = -1
 			}
 
-			if err := b.s.UpdateStatusComplex(dg.UpdateStatusData{
+			if err := b.gw.UpdateStatusComplex(dg.UpdateStatusData{
 				Status: string(dg.StatusOnline),
 				Activities: []*dg.Activity{
 					{
-						Name:  b.s.State.User.Username,
+						Name:  b.gw.State.User.Username,
 						Type:  dg.ActivityTypeCustom,
 						State: msg,
 					},
@@ -244,15 +522,28 @@ func (b *Bot) ensure(guildID string) *GuildContext {
 		return guildCtx
 	}
 
+	guildCtx := b.newGuildContext(guildID)
+	b.contexts[guildID] = guildCtx
+	return guildCtx
+}
+
+func (b *Bot) newGuildContext(guildID string) *GuildContext {
 	ctx, cancel := context.WithCancel(b.ctx)
 	guildCtx := &GuildContext{
-		Context: ctx,
-		Cancel:  cancel,
-		Events:  make(chan GuildEvent, 1000),
-		Relay:   make(chan string, 500),
+		Context:  ctx,
+		Cancel:   cancel,
+		Events:   make(chan GuildEvent, eventQueueSize),
+		Messages: make(chan GuildEvent, messageQueueSize),
+		Voice:    make(chan GuildEvent, voiceQueueSize),
+		Relay:    make(chan string, relayQueueSize),
 	}
 
-	b.contexts[guildID] = guildCtx
+	b.voices.Add(1)
+	go b.monitor(guildID, guildCtx)
+	go b.relay(guildID, guildCtx)
+	go b.messages(guildID, guildCtx)
+	go b.voice(guildID, guildCtx)
+
 	return guildCtx
 }
 
@@ -267,13 +558,8 @@ func (b *Bot) dispatch(guildID string) {
 	}()
 
 	ctx := b.ensure(guildID)
-
-	g, err := b.d.GetGuild(b.ctx, guildID)
-	if err != nil {
-		b.l.Error("error getting guild", "guild", guildID, "error", err)
-	}
-
-	go b.monitor(guildID, ctx)
+	ctx.Goroutines.Add(1)
+	defer ctx.Goroutines.Add(-1)
 
 	for {
 		select {
@@ -286,172 +572,376 @@ func (b *Bot) dispatch(guildID string) {
 				continue
 			}
 
-			if e.Interaction != nil {
-				if err := b.d.Create(b.ctx, models.Interaction{
-					Interaction: e.Interaction.Interaction,
-				}); err != nil {
-					b.l.Warn("error storing interaction", "error", err)
-				}
+			if e.Type == EventTypeInteraction {
+				b.interact(e.ctx, gc, guildID, e.Interaction)
 			}
 
-			switch e.Type {
-			case EventTypeInteraction:
-				g, err = b.d.GetGuild(b.ctx, guildID)
-				if err != nil {
-					b.r.Fail(e.Interaction, utils.Failure{
-						Type:    utils.ErrInternal,
-						Message: "Failed to fetch guild",
-						Data:    map[string]any{"error": err, "guild": e.Interaction.GuildID},
-					})
-					continue
-				}
-
-				i := e.Interaction
-				if i == nil {
-					b.l.Warn("received nil interaction in dispatch")
-					continue
-				}
-
-				switch i.Type {
-				case dg.InteractionApplicationCommand:
-					data := i.ApplicationCommandData()
-					opts := utils.MapOptions(i)
-
-					h, ok := lookup[data.Name]
-					if !ok {
-						b.r.Fail(i, utils.Failure{
-							Type:    utils.ErrNotFound,
-							Message: "No registered command",
-						})
-						continue
-					}
-
-					b.l.Info("command issued", "user", i.Member.User.Username, "called", utils.FormatInteraction(b.s, i))
-
-					go func() {
-						defer func() {
-							if r := recover(); r != nil {
-								stack := make([]byte, 4096)
-								stack = stack[:runtime.Stack(stack, false)]
-								b.l.Error("panic recovered", "command", h.Metadata().Name, "guild", guildID, "recovered", r, "stack", stack)
-							}
-						}()
-
-						if err := h.Handle(gc.Context, handlers.Dependencies{
-							Session:     b.s,
-							Database:    b.d,
-							Cache:       b.c,
-							Responder:   b.r,
-							Logger:      b.l,
-							Guild:       g,
-							Interaction: i,
-							Options:     &opts,
-						}); err != nil {
-							b.l.Error("error handling command", "error", err, "command", data.Name, "guild", guildID)
-							b.r.Fail(i, utils.Failure{
-								Type:    utils.ErrInternal,
-								Message: "Failed to handle command",
-								Data:    map[string]any{"error": err},
-							})
-						}
-					}()
-
-				case dg.InteractionMessageComponent:
-				}
+			trace.SpanFromContext(e.ctx).End()
+			if e.done != nil {
+				e.done()
 			}
 		}
 	}
 }
 
-func (b *Bot) load(guildID string) {
-	b.ensure(guildID)
+func (b *Bot) interact(ctx context.Context, gc *GuildContext, guildID string, i *dg.InteractionCreate) {
+	if i == nil {
+		b.l.Warn("received nil interaction in dispatch")
+		return
+	}
 
-	start := time.Now()
+	ctx, span := tracing.Start(ctx, "dispatch", interactionAttributes(i)...)
+	defer span.End()
 
-	g, err := b.d.GetGuild(b.ctx, guildID)
+	hctx := trace.ContextWithSpan(gc.Context, span)
+
+	g, err := b.d.GetGuild(ctx, guildID)
 	if err != nil {
-		b.l.Error("error getting guild", "error", err, "guild", guildID)
+		tracing.Fail(span, err)
+		b.reject(i, models.Interaction{Interaction: i.Interaction}.Command(), utils.Failure{
+			Type:    utils.ErrInternal,
+			Message: "Failed to fetch guild",
+			Data:    map[string]any{"error": err, "guild": i.GuildID},
+		})
 		return
 	}
 
-	var commands []*dg.ApplicationCommand
+	switch i.Type {
+	case dg.InteractionApplicationCommand:
+		data := i.ApplicationCommandData()
+		opts := utils.MapOptions(i)
+
+		name := g.CommandName(data.Name)
+		h, ok := lookup[key(data.CommandType, name)]
+		if !ok {
+			b.reject(i, name, utils.Failure{
+				Type:    utils.ErrNotFound,
+				Message: "No registered command",
+			})
+			return
+		}
+
+		if !g.CommandEnabled(name) {
+			b.reject(i, name, utils.Failure{
+				Type:    utils.ErrNotAllowed,
+				Message: "This command is disabled in this server.",
+				Data:    map[string]any{"command": name},
+			})
+			return
+		}
+
+		dep := b.dependencies(g, i)
+		dep.Options = &opts
 
-	for _, h := range lookup {
-		cmd := h.Metadata()
-		commands = append(commands, &cmd)
-	}
+		if data.CommandType == dg.UserApplicationCommand || data.CommandType == dg.MessageApplicationCommand {
+			user, member, message, ok := utils.ResolveTarget(data)
+			if !ok {
+				b.reject(i, name, utils.Failure{
+					Type:    utils.ErrNotFound,
+					Message: "Couldn't resolve the selected target",
+					Data:    map[string]any{"target": data.TargetID},
+				})
+				return
+			}
+
+			dep.TargetUser = user
+			dep.TargetMember = member
+			dep.TargetMessage = message
+		}
+
+		routed := h
+		if path := utils.SubcommandPath(i); len(path) > 0 {
+			dep.Subcommand = strings.Join(path, " ")
+			if r, ok := h.(handlers.Router); ok {
+				fn, ok := r.Subcommands()[dep.Subcommand]
+				if !ok {
+					b.reject(i, name, utils.Failure{
+						Type:    utils.ErrNotFound,
+						Message: "No registered subcommand",
+						Data:    map[string]any{"subcommand": dep.Subcommand},
+					})
+					return
+				}
+				routed = handlers.Wrap(h, fn)
+			}
+		}
+
+		b.spawn(gc, func() { b.handle(hctx, routed, dep) })
+
+	case dg.InteractionApplicationCommandAutocomplete:
+		data := i.ApplicationCommandData()
+		opts := utils.MapOptions(i)
+
+		h, ok := lookup[key(dg.ChatApplicationCommand, g.CommandName(data.Name))].(handlers.Autocompleter)
+		if !ok {
+			b.l.Warn("autocomplete requested for command without autocompleter", "command", data.Name, "guild", guildID)
+			b.r.Autocomplete(i, nil)
+			return
+		}
+
+		focused := utils.FocusedOption(data.Options)
+		if focused == nil {
+			b.r.Autocomplete(i, nil)
+			return
+		}
 
-	for i, cmd := range commands {
-		result := utils.ValidateCommand(cmd)
-		if result.WasModified {
-			commands[i] = result.Command
-			b.l.Warn("command was modified during validation", "command", cmd.Name, "errors", result.Errors, "guild", guildID)
+		dep := b.dependencies(g, i)
+		dep.Options = &opts
+		b.spawn(gc, func() { b.autocomplete(hctx, data.Name, h, focused, dep) })
+
+	case dg.InteractionMessageComponent:
+		data := i.MessageComponentData()
+
+		id, err := handlers.ParseCustomID(data.CustomID)
+		if err != nil {
+			b.reject(i, "", utils.Failure{
+				Type:    utils.ErrBadInput,
+				Message: "Unrecognized component",
+				Data:    map[string]any{"custom_id": data.CustomID},
+			})
+			return
+		}
+
+		h := lookup[key(dg.ChatApplicationCommand, id.Handler)]
+		c, ok := h.(handlers.ComponentHandler)
+		if !ok {
+			b.reject(i, id.Handler, utils.Failure{
+				Type:    utils.ErrNotFound,
+				Message: "No registered component handler",
+				Data:    map[string]any{"custom_id": data.CustomID},
+			})
+			return
+		}
+
+		if !g.CommandEnabled(id.Handler) {
+			b.reject(i, id.Handler, utils.Failure{
+				Type:    utils.ErrNotAllowed,
+				Message: "This command is disabled in this server.",
+				Data:    map[string]any{"command": id.Handler},
+			})
+			return
+		}
+
+		dep := b.dependencies(g, i)
+		dep.CustomID = &id
+		b.spawn(gc, func() { b.handle(hctx, handlers.Wrap(h, c.HandleComponent), dep) })
+
+	case dg.InteractionModalSubmit:
+		data := i.ModalSubmitData()
+
+		id, err := handlers.ParseCustomID(data.CustomID)
+		if err != nil {
+			b.reject(i, "", utils.Failure{
+				Type:    utils.ErrBadInput,
+				Message: "Unrecognized form",
+				Data:    map[string]any{"custom_id": data.CustomID},
+			})
+			return
+		}
+
+		h := lookup[key(dg.ChatApplicationCommand, id.Handler)]
+		m, ok := h.(handlers.ModalHandler)
+		if !ok {
+			b.reject(i, id.Handler, utils.Failure{
+				Type:    utils.ErrNotFound,
+				Message: "No registered form handler",
+				Data:    map[string]any{"custom_id": data.CustomID},
+			})
+			return
+		}
+
+		if !g.CommandEnabled(id.Handler) {
+			b.reject(i, id.Handler, utils.Failure{
+				Type:    utils.ErrNotAllowed,
+				Message: "This command is disabled in this server.",
+				Data:    map[string]any{"command": id.Handler},
+			})
+			return
 		}
+
+		dep := b.dependencies(g, i)
+		dep.CustomID = &id
+		b.spawn(gc, func() { b.handle(hctx, handlers.Wrap(h, m.HandleModal), dep) })
 	}
+}
 
-	var newHash string
-	bytes, err := json.Marshal(commands)
-	if err == nil {
-		hash := sha256.Sum256(bytes)
-		newHash = fmt.Sprintf("%x", hash)
+// reject fails an interaction before it reaches a handler, recording it the
+// way Persist and Measure record handled ones.
+func (b *Bot) reject(i *dg.InteractionCreate, name string, f utils.Failure) {
+	b.r.Fail(i, f)
+
+	outcome := models.OutcomeFailure
+	if f.Type == utils.ErrInternal {
+		outcome = models.OutcomeError
 	}
 
-	oldHash := g.Settings.CommandSetHash
-	if newHash == oldHash {
-		b.l.Info("command set unchanged", "guild", guildID)
-		return
+	b.record(models.Interaction{
+		Interaction: i.Interaction,
+		CommandName: name,
+		Outcome:     outcome,
+		Created:     time.Now().UTC(),
+	})
+	metrics.CommandsHandled.WithLabelValues(name, outcome, f.Type.String()).Inc()
+}
+
+func (b *Bot) dependencies(g *models.Guild, i *dg.InteractionCreate) handlers.Dependencies {
+	return handlers.Dependencies{
+		Session:     b.s,
+		Database:    b.d,
+		Cache:       b.c,
+		Responder:   b.r,
+		Registry:    b,
+		Logger:      b.l,
+		Guild:       g,
+		Interaction: i,
+		Relay:       func(message string) { b.Relay(g.ID, message) },
 	}
+}
 
-	if _, err := b.s.ApplicationCommandBulkOverwrite(b.s.State.User.ID, guildID, commands); err != nil {
-		b.l.Error("error loading guild commands", "error", err, "guild", guildID)
-		return
+func (b *Bot) handle(ctx context.Context, h handlers.Handler, dep handlers.Dependencies) {
+	b.mu.RLock()
+	mws := b.middleware
+	b.mu.RUnlock()
+
+	// Recover sits inside the reporting middleware so handler panics are
+	// reported as failures; this catches panics in the middleware itself.
+	defer func() {
+		if r := recover(); r != nil {
+			stack := make([]byte, 4096)
+			stack = stack[:runtime.Stack(stack, false)]
+			b.l.Error("panic recovered", "command", h.Metadata().Name, "guild", dep.Interaction.GuildID, "recovered", r, "stack", stack)
+		}
+	}()
+
+	if err := handlers.Chain(h, mws...).Handle(ctx, dep); err != nil {
+		b.l.Error("unhandled error from command", "error", err, "command", h.Metadata().Name, "guild", dep.Interaction.GuildID)
 	}
+}
+
+func (b *Bot) Use(mws ...handlers.Middleware) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.middleware = append(b.middleware[:len(b.middleware):len(b.middleware)], mws...)
+}
+
+func (b *Bot) autocomplete(ctx context.Context, name string, h handlers.Autocompleter, focused *dg.ApplicationCommandInteractionDataOption, dep handlers.Dependencies) {
+	defer func() {
+		if r := recover(); r != nil {
+			stack := make([]byte, 4096)
+			stack = stack[:runtime.Stack(stack, false)]
+			b.l.Error("panic recovered", "command", name, "guild", dep.Interaction.GuildID, "recovered", r, "stack", stack)
+		}
+	}()
 
-	if err := b.d.Update(b.ctx, models.TableGuilds, sq.Eq{"id": guildID}, map[string]any{
-		"settings": sq.Expr("jsonb_set(COALESCE(settings, '{}'::jsonb), '{command_set_hash}', to_jsonb(?::text))", newHash),
-	}); err != nil {
-		b.l.Warn("error updating command set hash", "error", err, "guild", guildID, "hash", newHash)
+	choices, err := h.Autocomplete(ctx, dep, focused)
+	if err != nil {
+		b.l.Error("error handling autocomplete", "error", err, "command", name, "option", focused.Name, "guild", dep.Interaction.GuildID)
+		choices = nil
 	}
 
-	b.l.Info("command set loaded", "loaded", len(commands), "duration", time.Since(start))
+	if err := b.r.Autocomplete(dep.Interaction, choices); err != nil {
+		b.l.Warn("error responding to autocomplete", "error", err, "command", name, "guild", dep.Interaction.GuildID)
+	}
 }
 
 func (b *Bot) enqueue(guildID string, event GuildEvent) {
+	attrs := []attribute.KeyValue{attribute.String("event.type", event.Type.String()), attribute.String("guild.id", guildID)}
+	if event.Interaction != nil {
+		attrs = interactionAttributes(event.Interaction)
+	}
+
+	var span trace.Span
+	event.ctx, span = tracing.Start(b.ctx, "gateway.event", attrs...)
+
 	b.mu.RLock()
 	ctx, ok := b.contexts[guildID]
+	closing := b.closing
 	b.mu.RUnlock()
 
+	drop := func(reason string) {
+		metrics.EventsDropped.WithLabelValues(reason).Inc()
+		span.SetStatus(codes.Error, "dropped: "+reason)
+		span.End()
+	}
+
+	if closing {
+		drop("shutdown")
+		b.l.Debug("dropped event during shutdown", "guild", guildID)
+		return
+	}
+
 	if !ok {
+		drop("unknown_guild")
 		b.l.Warn("attempted to enqueue event for unknown guild", "guild", guildID)
 		return
 	}
 
+	queue := ctx.Events
+	switch event.Type {
+	case EventTypeMsgCreation, EventTypeMsgUpdate, EventTypeMsgDeletion:
+		queue = ctx.Messages
+	case EventTypeVoiceUpdate:
+		queue = ctx.Voice
+	}
+
 	select {
-	case ctx.Events <- event:
+	case queue <- event:
 	case <-ctx.Context.Done():
+		drop("cancelled")
 		b.l.Debug("dropped event for cancelled guild context", "guild", guildID)
 	default:
-		b.l.Warn("event channel full, dropping event", "guild", guildID)
+		drop("full")
+		b.l.Warn("event channel full, dropping event", "guild", guildID, "type", event.Type)
 	}
 }
 
+func interactionAttributes(i *dg.InteractionCreate) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.String("event.type", EventTypeInteraction.String()),
+		attribute.String("interaction.id", i.ID),
+		attribute.String("guild.id", i.GuildID),
+	}
+
+	switch i.Type {
+	case dg.InteractionApplicationCommand, dg.InteractionApplicationCommandAutocomplete:
+		attrs = append(attrs, attribute.String("command.name", i.ApplicationCommandData().Name))
+	case dg.InteractionMessageComponent:
+		attrs = append(attrs, attribute.String("component.custom_id", i.MessageComponentData().CustomID))
+	case dg.InteractionModalSubmit:
+		attrs = append(attrs, attribute.String("component.custom_id", i.ModalSubmitData().CustomID))
+	}
+
+	return attrs
+}
+
 func (b *Bot) register(g *dg.Guild) {
+	if !b.track(g) {
+		return
+	}
+
+	go func() {
+		if err := b.load(g.ID); err != nil {
+			b.l.Error("error loading guild commands", "error", err, "guild", g.ID)
+		}
+	}()
+	go b.dispatch(g.ID)
+	b.reconcileVoice(g)
+}
+
+func (b *Bot) track(g *dg.Guild) bool {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	if existing, ok := b.contexts[g.ID]; ok {
-		existing.Cancel()
+	if b.closing {
+		return false
 	}
 
-	ctx, cancel := context.WithCancel(b.ctx)
-
-	guildCtx := &GuildContext{
-		Context: ctx,
-		Cancel:  cancel,
-		Events:  make(chan GuildEvent),
-		Relay:   make(chan string),
+	if existing, ok := b.contexts[g.ID]; ok {
+		existing.Cancel()
 	}
 
+	guildCtx := b.newGuildContext(g.ID)
 	b.contexts[g.ID] = guildCtx
 
 	stored, err := b.d.GetGuild(b.ctx, g.ID)
@@ -459,27 +949,25 @@ func (b *Bot) register(g *dg.Guild) {
 		if errors.Is(err, sql.ErrNoRows) {
 			if err := b.d.PutGuild(b.ctx, models.Guild{ID: g.ID, Name: g.Name}); err != nil {
 				b.l.Error("error storing new guild", "error", err)
-				return
+				return false
 			}
 		} else {
 			b.l.Error("error fetching guild", "error", err)
-			return
+			return false
 		}
 	} else {
 		stored.Name = g.Name
 		if err := b.d.Update(b.ctx, models.TableGuilds, sq.Eq{"id": g.ID}, map[string]any{
 			"name":     g.Name,
-			"settings": sq.Expr("jsonb_set(COALESCE(settings, '{}'::jsonb), '{command_set_hash}', to_jsonb(?::text))", stored.Settings.CommandSetHash),
+			"settings": database.SetJSON("settings", stored.Settings.CommandSetHash, "command_set_hash"),
 		}); err != nil {
 			b.l.Error("error updating guild", "error", err)
-			return
+			return false
 		}
 	}
 
 	b.l.Info("registered guild", "id", g.ID, "name", g.Name)
-
-	go b.load(g.ID)
-	go b.dispatch(g.ID)
+	return true
 }
 
 func (b *Bot) remove(g *dg.Guild) {
@@ -490,11 +978,15 @@ func (b *Bot) remove(g *dg.Guild) {
 		guildCtx.Cancel()
 		delete(b.contexts, g.ID)
 	}
+	metrics.QueueDepth.DeleteLabelValues(g.ID)
 
 	b.l.Info("removed guild", "id", g.ID)
 }
 
 func (b *Bot) monitor(guildID string, ctx *GuildContext) {
+	ctx.Goroutines.Add(1)
+	defer ctx.Goroutines.Add(-1)
+
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
 
@@ -506,19 +998,10 @@ func (b *Bot) monitor(guildID string, ctx *GuildContext) {
 		case <-ctx.Context.Done():
 			return
 		case <-ticker.C:
-			channelSize := 0
-			for i := 0; i < 500; i++ {
-				select {
-				case <-ctx.Events:
-					b.l.Error("unexpectedly removed event during channel monitoring", "guild", guildID)
-					channelSize++
-				default:
-					i = 500
-				}
-			}
-
 			currentLen := len(ctx.Events)
 			capacity := cap(ctx.Events)
+			metrics.QueueDepth.WithLabelValues(guildID).Set(float64(currentLen))
+
 			fillPercentage := float64(currentLen) / float64(capacity) * 100
 
 			if fillPercentage > 60 {
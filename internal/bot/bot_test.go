@@ -0,0 +1,1098 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/glotchimo/recast/internal/database"
+	"github.com/glotchimo/recast/internal/models"
+	"github.com/glotchimo/recast/internal/session"
+)
+
+const (
+	testGuildID = "1"
+	testUserID  = "2"
+)
+
+func newTestHarness(t *testing.T, conf Config) *Harness {
+	t.Helper()
+
+	h := NewHarness(conf)
+	t.Cleanup(func() { h.Close() })
+
+	if err := h.AddGuild(&dg.Guild{ID: testGuildID, Name: "guild"}); err != nil {
+		t.Fatalf("error adding guild: %v", err)
+	}
+
+	return h
+}
+
+func admin(i *dg.InteractionCreate) *dg.InteractionCreate {
+	i.Member.Permissions = dg.PermissionManageGuild
+	return i
+}
+
+func setCommand(t *testing.T, h *Harness, name string, cs models.CommandSettings) {
+	t.Helper()
+
+	if err := h.Store.Update(context.Background(), models.TableGuilds, sq.Eq{"id": testGuildID}, map[string]any{
+		"settings": database.SetJSON("settings", cs, "commands", name),
+	}); err != nil {
+		t.Fatalf("error updating command settings: %v", err)
+	}
+}
+
+var responseTypes = map[dg.InteractionResponseType]string{
+	dg.InteractionResponseChannelMessageWithSource:         "message",
+	dg.InteractionResponseDeferredChannelMessageWithSource: "deferred",
+	dg.InteractionResponseDeferredMessageUpdate:            "deferred update",
+	dg.InteractionResponseUpdateMessage:                    "update",
+	dg.InteractionApplicationCommandAutocompleteResult:     "autocomplete",
+	dg.InteractionResponseModal:                            "modal",
+}
+
+// describe summarises the calls a handler made so tests can compare them as
+// plain strings.
+func describe(calls []session.Call) []string {
+	title := func(embeds []*dg.MessageEmbed) string {
+		if len(embeds) == 0 || embeds[0].Title == "" {
+			return ""
+		}
+		return " " + embeds[0].Title
+	}
+
+	var out []string
+	for _, c := range calls {
+		switch c.Method {
+		case "InteractionRespond":
+			s := "respond " + responseTypes[c.Response.Type]
+			if c.Response.Data != nil {
+				s += title(c.Response.Data.Embeds)
+			}
+			out = append(out, s)
+		case "FollowupMessageCreate":
+			out = append(out, "followup"+title(c.Params.Embeds))
+		case "InteractionResponseEdit":
+			s := "edit"
+			if c.Edit.Embeds != nil {
+				s += title(*c.Edit.Embeds)
+			}
+			out = append(out, s)
+		default:
+			out = append(out, c.Method)
+		}
+	}
+	return out
+}
+
+func TestPing(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	calls, err := h.Interact(h.Command(testGuildID, testUserID, "ping"))
+	if err != nil {
+		t.Fatalf("error interacting: %v", err)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("got %d calls, want 2: %+v", len(calls), calls)
+	}
+
+	deferred := calls[0]
+	if deferred.Method != "InteractionRespond" {
+		t.Fatalf("got first call %s, want InteractionRespond", deferred.Method)
+	}
+	if deferred.Response.Type != dg.InteractionResponseDeferredChannelMessageWithSource {
+		t.Errorf("got response type %d, want deferred", deferred.Response.Type)
+	}
+	if deferred.Response.Data == nil || deferred.Response.Data.Flags&dg.MessageFlagsEphemeral == 0 {
+		t.Errorf("deferred response is not ephemeral")
+	}
+
+	followup := calls[1]
+	if followup.Method != "FollowupMessageCreate" {
+		t.Fatalf("got second call %s, want FollowupMessageCreate", followup.Method)
+	}
+	if len(followup.Params.Embeds) != 1 || followup.Params.Embeds[0].Title != "Pong!" {
+		t.Errorf("got embeds %+v, want a single Pong! embed", followup.Params.Embeds)
+	}
+	if followup.Params.Flags&dg.MessageFlagsEphemeral == 0 {
+		t.Errorf("followup is not ephemeral")
+	}
+}
+
+func TestComponent(t *testing.T) {
+	tests := []struct {
+		name     string
+		customID string
+		disabled bool
+		want     []string
+		alias    string
+	}{
+		{
+			name:     "confirm",
+			customID: "commands:reset:ping",
+			want:     []string{"respond deferred update", "edit Commands Updated"},
+		},
+		{
+			name:     "cancel",
+			customID: "commands:cancel",
+			want:     []string{"respond update Reset Cancelled"},
+			alias:    "pong",
+		},
+		{
+			name:     "unknown action",
+			customID: "commands:explode",
+			want:     []string{"respond message Invalid Input"},
+			alias:    "pong",
+		},
+		{
+			name:     "unparseable custom ID",
+			customID: ":reset",
+			want:     []string{"respond message Invalid Input"},
+			alias:    "pong",
+		},
+		{
+			name:     "unknown handler",
+			customID: "nope:reset",
+			want:     []string{"respond message Not Found"},
+			alias:    "pong",
+		},
+		{
+			name:     "handler without components",
+			customID: "ping:reset",
+			want:     []string{"respond message Not Found"},
+			alias:    "pong",
+		},
+		{
+			name:     "disabled handler",
+			customID: "commands:reset:ping",
+			disabled: true,
+			want:     []string{"respond message Permission Denied"},
+			alias:    "pong",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHarness(t, Config{})
+			setCommand(t, h, "ping", models.CommandSettings{Alias: "pong"})
+			setCommand(t, h, "commands", models.CommandSettings{Disabled: tt.disabled})
+
+			calls, err := h.Interact(admin(h.Component(testGuildID, testUserID, tt.customID)))
+			if err != nil {
+				t.Fatalf("error interacting: %v", err)
+			}
+			if got := describe(calls); !slices.Equal(got, tt.want) {
+				t.Errorf("got calls %q, want %q", got, tt.want)
+			}
+
+			g, err := h.Store.GetGuild(context.Background(), testGuildID)
+			if err != nil {
+				t.Fatalf("error fetching guild: %v", err)
+			}
+			if got := g.Settings.Commands["ping"].Alias; got != tt.alias {
+				t.Errorf("got alias %q, want %q", got, tt.alias)
+			}
+		})
+	}
+}
+
+func TestResetConfirmation(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	calls, err := h.Interact(admin(h.Command(testGuildID, testUserID, "commands", &dg.ApplicationCommandInteractionDataOption{
+		Name: "reset",
+		Type: dg.ApplicationCommandOptionSubCommand,
+		Options: []*dg.ApplicationCommandInteractionDataOption{
+			{Name: "command", Type: dg.ApplicationCommandOptionString, Value: "ping"},
+		},
+	})))
+	if err != nil {
+		t.Fatalf("error interacting: %v", err)
+	}
+	if got, want := describe(calls), []string{"respond deferred", "followup Reset Command"}; !slices.Equal(got, want) {
+		t.Fatalf("got calls %q, want %q", got, want)
+	}
+
+	var ids []string
+	for _, c := range calls[1].Params.Components {
+		row, ok := c.(dg.ActionsRow)
+		if !ok {
+			t.Fatalf("got component %T, want an actions row", c)
+		}
+		for _, b := range row.Components {
+			ids = append(ids, b.(dg.Button).CustomID)
+		}
+	}
+	if want := []string{"commands:reset:ping", "commands:cancel"}; !slices.Equal(ids, want) {
+		t.Errorf("got buttons %q, want %q", ids, want)
+	}
+}
+
+func TestModal(t *testing.T) {
+	input := func(value string) dg.MessageComponent {
+		return dg.ActionsRow{Components: []dg.MessageComponent{dg.TextInput{CustomID: "name", Value: value}}}
+	}
+
+	tests := []struct {
+		name     string
+		customID string
+		value    string
+		disabled bool
+		want     []string
+		alias    string
+	}{
+		{
+			name:     "rename",
+			customID: "commands:rename:ping",
+			value:    "pong",
+			want:     []string{"respond deferred", "ApplicationCommandBulkOverwrite", "followup Commands Updated"},
+			alias:    "pong",
+		},
+		{
+			name:     "missing value",
+			customID: "commands:rename:ping",
+			want:     []string{"respond message Invalid Input"},
+		},
+		{
+			name:     "invalid name",
+			customID: "commands:rename:ping",
+			value:    "Not A Name",
+			want:     []string{"respond message Invalid Input"},
+		},
+		{
+			name:     "unknown action",
+			customID: "commands:explode:ping",
+			value:    "pong",
+			want:     []string{"respond message Invalid Input"},
+		},
+		{
+			name:     "unparseable custom ID",
+			customID: ":rename",
+			value:    "pong",
+			want:     []string{"respond message Invalid Input"},
+		},
+		{
+			name:     "handler without forms",
+			customID: "ping:rename",
+			value:    "pong",
+			want:     []string{"respond message Not Found"},
+		},
+		{
+			name:     "disabled handler",
+			customID: "commands:rename:ping",
+			value:    "pong",
+			disabled: true,
+			want:     []string{"respond message Permission Denied"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHarness(t, Config{})
+			setCommand(t, h, "commands", models.CommandSettings{Disabled: tt.disabled})
+
+			calls, err := h.Interact(admin(h.Modal(testGuildID, testUserID, tt.customID, input(tt.value))))
+			if err != nil {
+				t.Fatalf("error interacting: %v", err)
+			}
+			if got := describe(calls); !slices.Equal(got, tt.want) {
+				t.Errorf("got calls %q, want %q", got, tt.want)
+			}
+
+			g, err := h.Store.GetGuild(context.Background(), testGuildID)
+			if err != nil {
+				t.Fatalf("error fetching guild: %v", err)
+			}
+			if got := g.Settings.Commands["ping"].Alias; got != tt.alias {
+				t.Errorf("got alias %q, want %q", got, tt.alias)
+			}
+		})
+	}
+}
+
+func TestRenameForm(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	setCommand(t, h, "ping", models.CommandSettings{Alias: "pong"})
+
+	calls, err := h.Interact(admin(h.Command(testGuildID, testUserID, "commands", &dg.ApplicationCommandInteractionDataOption{
+		Name: "rename",
+		Type: dg.ApplicationCommandOptionSubCommand,
+		Options: []*dg.ApplicationCommandInteractionDataOption{
+			{Name: "command", Type: dg.ApplicationCommandOptionString, Value: "ping"},
+		},
+	})))
+	if err != nil {
+		t.Fatalf("error interacting: %v", err)
+	}
+	if got, want := describe(calls), []string{"respond modal"}; !slices.Equal(got, want) {
+		t.Fatalf("got calls %q, want %q", got, want)
+	}
+
+	data := calls[0].Response.Data
+	if data.CustomID != "commands:rename:ping" {
+		t.Errorf("got custom ID %q, want commands:rename:ping", data.CustomID)
+	}
+
+	row := data.Components[0].(dg.ActionsRow)
+	if input := row.Components[0].(dg.TextInput); input.CustomID != "name" || input.Value != "pong" {
+		t.Errorf("got input %+v, want name prefilled with the current alias", input)
+	}
+}
+
+func TestAutocomplete(t *testing.T) {
+	focused := func(subcommand, value string) *dg.ApplicationCommandInteractionDataOption {
+		return &dg.ApplicationCommandInteractionDataOption{
+			Name: subcommand,
+			Type: dg.ApplicationCommandOptionSubCommand,
+			Options: []*dg.ApplicationCommandInteractionDataOption{
+				{Name: "command", Type: dg.ApplicationCommandOptionString, Value: value, Focused: true},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		command string
+		options []*dg.ApplicationCommandInteractionDataOption
+		want    []string
+	}{
+		{
+			name:    "prefix",
+			command: "commands",
+			options: []*dg.ApplicationCommandInteractionDataOption{focused("disable", "P")},
+			want:    []string{"ping"},
+		},
+		{
+			name:    "no matches",
+			command: "commands",
+			options: []*dg.ApplicationCommandInteractionDataOption{focused("disable", "zzz")},
+		},
+		{
+			name:    "renamed command",
+			command: "cmds",
+			options: []*dg.ApplicationCommandInteractionDataOption{focused("enable", "re")},
+			want:    []string{"retention"},
+		},
+		{
+			name:    "no focused option",
+			command: "commands",
+			options: []*dg.ApplicationCommandInteractionDataOption{{Name: "list", Type: dg.ApplicationCommandOptionSubCommand}},
+		},
+		{
+			name:    "command without autocompleter",
+			command: "ping",
+		},
+		{
+			name:    "unknown command",
+			command: "nope",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHarness(t, Config{})
+			setCommand(t, h, "commands", models.CommandSettings{Alias: "cmds"})
+
+			calls, err := h.Interact(h.Autocomplete(testGuildID, testUserID, tt.command, tt.options...))
+			if err != nil {
+				t.Fatalf("error interacting: %v", err)
+			}
+			if got, want := describe(calls), []string{"respond autocomplete"}; !slices.Equal(got, want) {
+				t.Fatalf("got calls %q, want %q", got, want)
+			}
+
+			var got []string
+			for _, c := range calls[0].Response.Data.Choices {
+				got = append(got, c.Name)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got choices %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContextMenu(t *testing.T) {
+	target := &dg.User{ID: "3", Username: "target"}
+
+	tests := []struct {
+		name string
+		i    func(h *Harness) *dg.InteractionCreate
+		want []string
+	}{
+		{
+			name: "user command",
+			i: func(h *Harness) *dg.InteractionCreate {
+				return h.UserCommand(testGuildID, testUserID, "Voice History", target)
+			},
+			want: []string{"respond deferred", "followup Voice history"},
+		},
+		{
+			name: "unresolved target",
+			i: func(h *Harness) *dg.InteractionCreate {
+				return h.UserCommand(testGuildID, testUserID, "Voice History", nil)
+			},
+			want: []string{"respond message Not Found"},
+		},
+		{
+			name: "chat command name as user command",
+			i: func(h *Harness) *dg.InteractionCreate {
+				return h.UserCommand(testGuildID, testUserID, "voice", target)
+			},
+			want: []string{"respond message Not Found"},
+		},
+		{
+			name: "user command name as chat command",
+			i: func(h *Harness) *dg.InteractionCreate {
+				return h.Command(testGuildID, testUserID, "Voice History")
+			},
+			want: []string{"respond message Not Found"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHarness(t, Config{})
+
+			calls, err := h.Interact(tt.i(h))
+			if err != nil {
+				t.Fatalf("error interacting: %v", err)
+			}
+			if got := describe(calls); !slices.Equal(got, tt.want) {
+				t.Fatalf("got calls %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContextMenuTarget(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	calls, err := h.Interact(h.UserCommand(testGuildID, testUserID, "Voice History", &dg.User{ID: "3"}))
+	if err != nil {
+		t.Fatalf("error interacting: %v", err)
+	}
+	if len(calls) != 2 || len(calls[1].Params.Embeds) != 1 {
+		t.Fatalf("got calls %q, want a single embed followup", describe(calls))
+	}
+	if got := calls[1].Params.Embeds[0].Description; !strings.Contains(got, "<@3>") {
+		t.Errorf("got description %q, want it to mention the target", got)
+	}
+}
+
+func TestSubcommands(t *testing.T) {
+	subcommand := func(name string, options ...*dg.ApplicationCommandInteractionDataOption) *dg.ApplicationCommandInteractionDataOption {
+		return &dg.ApplicationCommandInteractionDataOption{Name: name, Type: dg.ApplicationCommandOptionSubCommand, Options: options}
+	}
+	days := func(n int) *dg.ApplicationCommandInteractionDataOption {
+		return &dg.ApplicationCommandInteractionDataOption{Name: "days", Type: dg.ApplicationCommandOptionInteger, Value: float64(n)}
+	}
+
+	tests := []struct {
+		name      string
+		command   string
+		options   []*dg.ApplicationCommandInteractionDataOption
+		want      []string
+		retention *int
+	}{
+		{
+			name:      "set",
+			command:   "retention",
+			options:   []*dg.ApplicationCommandInteractionDataOption{subcommand("set", days(30))},
+			want:      []string{"respond deferred", "followup Retention Updated"},
+			retention: ptr(30),
+		},
+		{
+			name:    "subcommand options",
+			command: "retention",
+			options: []*dg.ApplicationCommandInteractionDataOption{subcommand("set", days(5000))},
+			want:    []string{"respond message Invalid Input"},
+		},
+		{
+			name:    "reset",
+			command: "retention",
+			options: []*dg.ApplicationCommandInteractionDataOption{subcommand("reset")},
+			want:    []string{"respond deferred", "followup Retention Reset"},
+		},
+		{
+			name:    "no subcommand",
+			command: "retention",
+			want:    []string{"respond message Invalid Input"},
+		},
+		{
+			name:    "unknown subcommand",
+			command: "retention",
+			options: []*dg.ApplicationCommandInteractionDataOption{subcommand("explode")},
+			want:    []string{"respond message Not Found"},
+		},
+		{
+			name:    "handler without router",
+			command: "ping",
+			options: []*dg.ApplicationCommandInteractionDataOption{subcommand("loud")},
+			want:    []string{"respond deferred", "followup Pong!"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHarness(t, Config{})
+
+			calls, err := h.Interact(admin(h.Command(testGuildID, testUserID, tt.command, tt.options...)))
+			if err != nil {
+				t.Fatalf("error interacting: %v", err)
+			}
+			if got := describe(calls); !slices.Equal(got, tt.want) {
+				t.Errorf("got calls %q, want %q", got, tt.want)
+			}
+
+			g, err := h.Store.GetGuild(context.Background(), testGuildID)
+			if err != nil {
+				t.Fatalf("error fetching guild: %v", err)
+			}
+			if got := g.Settings.RetentionDays; (got == nil) != (tt.retention == nil) || (got != nil && *got != *tt.retention) {
+				t.Errorf("got retention %v, want %v", got, tt.retention)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestOutcomes(t *testing.T) {
+	retention := &dg.ApplicationCommandInteractionDataOption{Name: "reset", Type: dg.ApplicationCommandOptionSubCommand}
+	failDefer := func(c session.Call) error {
+		if c.Method == "InteractionRespond" && c.Response.Type == dg.InteractionResponseDeferredChannelMessageWithSource {
+			return errors.New("unavailable")
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name    string
+		i       func(h *Harness) *dg.InteractionCreate
+		setup   func(t *testing.T, h *Harness)
+		err     func(session.Call) error
+		want    []string
+		outcome string
+	}{
+		{
+			name:    "ok",
+			i:       func(h *Harness) *dg.InteractionCreate { return h.Command(testGuildID, testUserID, "ping") },
+			want:    []string{"respond deferred", "followup Pong!"},
+			outcome: models.OutcomeOK,
+		},
+		{
+			name: "failure",
+			i: func(h *Harness) *dg.InteractionCreate {
+				return h.Command(testGuildID, testUserID, "retention", retention)
+			},
+			want:    []string{"respond message Permission Denied"},
+			outcome: models.OutcomeFailure,
+		},
+		{
+			name:    "error",
+			i:       func(h *Harness) *dg.InteractionCreate { return h.Command(testGuildID, testUserID, "ping") },
+			err:     failDefer,
+			want:    []string{"respond deferred", "respond message"},
+			outcome: models.OutcomeError,
+		},
+		{
+			name:    "unknown command",
+			i:       func(h *Harness) *dg.InteractionCreate { return h.Command(testGuildID, testUserID, "nope") },
+			want:    []string{"respond message Not Found"},
+			outcome: models.OutcomeFailure,
+		},
+		{
+			name:    "disabled command",
+			i:       func(h *Harness) *dg.InteractionCreate { return h.Command(testGuildID, testUserID, "ping") },
+			setup:   func(t *testing.T, h *Harness) { setCommand(t, h, "ping", models.CommandSettings{Disabled: true}) },
+			want:    []string{"respond message Permission Denied"},
+			outcome: models.OutcomeFailure,
+		},
+		{
+			name:    "unparseable custom ID",
+			i:       func(h *Harness) *dg.InteractionCreate { return h.Component(testGuildID, testUserID, ":reset") },
+			want:    []string{"respond message Invalid Input"},
+			outcome: models.OutcomeFailure,
+		},
+		{
+			name:    "handler without components",
+			i:       func(h *Harness) *dg.InteractionCreate { return h.Component(testGuildID, testUserID, "ping:reset") },
+			want:    []string{"respond message Not Found"},
+			outcome: models.OutcomeFailure,
+		},
+		{
+			name:    "handler without forms",
+			i:       func(h *Harness) *dg.InteractionCreate { return h.Modal(testGuildID, testUserID, "ping:rename") },
+			want:    []string{"respond message Not Found"},
+			outcome: models.OutcomeFailure,
+		},
+		{
+			name: "disabled component handler",
+			i: func(h *Harness) *dg.InteractionCreate {
+				return admin(h.Component(testGuildID, testUserID, "commands:reset:ping"))
+			},
+			setup:   func(t *testing.T, h *Harness) { setCommand(t, h, "commands", models.CommandSettings{Disabled: true}) },
+			want:    []string{"respond message Permission Denied"},
+			outcome: models.OutcomeFailure,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHarness(t, Config{})
+			h.Session.Err = tt.err
+			if tt.setup != nil {
+				tt.setup(t, h)
+			}
+
+			i := tt.i(h)
+			calls, err := h.Interact(i)
+			if err != nil {
+				t.Fatalf("error interacting: %v", err)
+			}
+			if got := describe(calls); !slices.Equal(got, tt.want) {
+				t.Errorf("got calls %q, want %q", got, tt.want)
+			}
+
+			if err := h.Flush(); err != nil {
+				t.Fatalf("error flushing: %v", err)
+			}
+			n, err := h.Store.Count(context.Background(), models.TableInteractions, sq.Eq{"id": i.ID, "outcome": tt.outcome})
+			if err != nil {
+				t.Fatalf("error counting interactions: %v", err)
+			}
+			if n != 1 {
+				t.Errorf("got %d interactions with outcome %q, want 1", n, tt.outcome)
+			}
+		})
+	}
+}
+
+// relayed waits for the relay worker to post a line containing until and
+// returns every line posted up to then.
+func relayed(t *testing.T, h *Harness, until string) []string {
+	t.Helper()
+
+	deadline := time.Now().Add(h.Timeout)
+	for time.Now().Before(deadline) {
+		var lines []string
+		for _, c := range h.Session.Calls() {
+			if c.Method != "ChannelMessageSendComplex" {
+				continue
+			}
+			for _, e := range c.Message.Embeds {
+				lines = append(lines, strings.Split(e.Description, "\n")...)
+			}
+		}
+		if slices.ContainsFunc(lines, func(line string) bool { return strings.Contains(line, until) }) {
+			return lines
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	t.Fatalf("nothing containing %q was relayed within %s", until, h.Timeout)
+	return nil
+}
+
+func TestAudit(t *testing.T) {
+	h := newTestHarness(t, Config{})
+	if err := h.Store.Update(context.Background(), models.TableGuilds, sq.Eq{"id": testGuildID}, map[string]any{
+		"settings": database.SetJSON("settings", "10", "log_channel_id"),
+	}); err != nil {
+		t.Fatalf("error setting log channel: %v", err)
+	}
+
+	denied := h.Command(testGuildID, testUserID, "retention", &dg.ApplicationCommandInteractionDataOption{Name: "reset", Type: dg.ApplicationCommandOptionSubCommand})
+	interactions := []*dg.InteractionCreate{denied}
+	for range 4 {
+		interactions = append(interactions, admin(h.Command(testGuildID, testUserID, "stats")))
+	}
+	interactions = append(interactions, h.Command(testGuildID, testUserID, "ping"))
+
+	for _, i := range interactions {
+		if _, err := h.Interact(i); err != nil {
+			t.Fatalf("error interacting: %v", err)
+		}
+	}
+
+	// Lines are posted in order, so everything relayed for the refused
+	// commands is in by the time ping's use is.
+	lines := relayed(t, h, "used `/ping`")
+	count := func(s string) int {
+		var n int
+		for _, line := range lines {
+			if strings.Contains(line, s) {
+				n++
+			}
+		}
+		return n
+	}
+
+	if n := count("used `/retention"); n != 0 {
+		t.Errorf("denied command was audited as used %d times", n)
+	}
+	if n := count("`retention` failed"); n != 1 {
+		t.Errorf("got %d denials relayed, want 1", n)
+	}
+	if n := count("used `/stats`"); n != 3 {
+		t.Errorf("got %d uses of /stats relayed, want 3 before the cooldown", n)
+	}
+	if n := count("`stats` failed"); n != 1 {
+		t.Errorf("got %d cooldown refusals relayed, want 1", n)
+	}
+}
+
+func TestCooldown(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	run := func(name, userID string) []string {
+		t.Helper()
+
+		calls, err := h.Interact(admin(h.Command(testGuildID, userID, name)))
+		if err != nil {
+			t.Fatalf("error interacting: %v", err)
+		}
+		return describe(calls)
+	}
+	stats := func(userID string) []string { return run("stats", userID) }
+
+	for n := range 3 {
+		if got, want := stats(testUserID), []string{"respond deferred", "followup Usage over the last 30 days"}; !slices.Equal(got, want) {
+			t.Fatalf("use %d: got calls %q, want %q", n+1, got, want)
+		}
+	}
+
+	// The cooldown is per guild, so another admin is refused too.
+	for _, userID := range []string{testUserID, "3"} {
+		if got, want := stats(userID), []string{"respond message Cooldown Active"}; !slices.Equal(got, want) {
+			t.Errorf("user %s: got calls %q, want %q", userID, got, want)
+		}
+	}
+
+	// Other commands have their own counters.
+	if got, want := run("ping", testUserID), []string{"respond deferred", "followup Pong!"}; !slices.Equal(got, want) {
+		t.Errorf("got calls %q, want %q", got, want)
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	list := &dg.ApplicationCommandInteractionDataOption{Name: "list", Type: dg.ApplicationCommandOptionSubCommand}
+	rename := dg.ActionsRow{Components: []dg.MessageComponent{dg.TextInput{CustomID: "name", Value: "pong"}}}
+
+	tests := []struct {
+		name        string
+		i           func(h *Harness) *dg.InteractionCreate
+		permissions int64
+		want        []string
+	}{
+		{
+			name: "missing permission",
+			i:    func(h *Harness) *dg.InteractionCreate { return h.Command(testGuildID, testUserID, "commands", list) },
+			want: []string{"respond message Permission Denied"},
+		},
+		{
+			name:        "unrelated permission",
+			i:           func(h *Harness) *dg.InteractionCreate { return h.Command(testGuildID, testUserID, "commands", list) },
+			permissions: dg.PermissionManageMessages,
+			want:        []string{"respond message Permission Denied"},
+		},
+		{
+			name:        "required permission",
+			i:           func(h *Harness) *dg.InteractionCreate { return h.Command(testGuildID, testUserID, "commands", list) },
+			permissions: dg.PermissionManageGuild,
+			want:        []string{"respond deferred", "followup Commands"},
+		},
+		{
+			name:        "administrator",
+			i:           func(h *Harness) *dg.InteractionCreate { return h.Command(testGuildID, testUserID, "commands", list) },
+			permissions: dg.PermissionAdministrator,
+			want:        []string{"respond deferred", "followup Commands"},
+		},
+		{
+			name: "unrestricted command",
+			i:    func(h *Harness) *dg.InteractionCreate { return h.Command(testGuildID, testUserID, "ping") },
+			want: []string{"respond deferred", "followup Pong!"},
+		},
+		{
+			name: "component",
+			i: func(h *Harness) *dg.InteractionCreate {
+				return h.Component(testGuildID, testUserID, "commands:reset:ping")
+			},
+			want: []string{"respond message Permission Denied"},
+		},
+		{
+			name: "form",
+			i: func(h *Harness) *dg.InteractionCreate {
+				return h.Modal(testGuildID, testUserID, "commands:rename:ping", rename)
+			},
+			want: []string{"respond message Permission Denied"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHarness(t, Config{})
+
+			i := tt.i(h)
+			i.Member.Permissions = tt.permissions
+
+			calls, err := h.Interact(i)
+			if err != nil {
+				t.Fatalf("error interacting: %v", err)
+			}
+			if got := describe(calls); !slices.Equal(got, tt.want) {
+				t.Errorf("got calls %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGuildCommands(t *testing.T) {
+	overwrites := func(h *Harness) [][]*dg.ApplicationCommand {
+		var out [][]*dg.ApplicationCommand
+		for _, c := range h.Session.Calls() {
+			if c.Method == "ApplicationCommandBulkOverwrite" && c.GuildID == testGuildID {
+				out = append(out, c.Commands)
+			}
+		}
+		return out
+	}
+
+	t.Run("global", func(t *testing.T) {
+		h := newTestHarness(t, Config{Registration: RegisterGlobal})
+
+		got := overwrites(h)
+		if len(got) != 1 {
+			t.Fatalf("got %d overwrites, want 1", len(got))
+		}
+		body, err := json.Marshal(got[0])
+		if err != nil {
+			t.Fatalf("error marshaling commands: %v", err)
+		}
+		if string(body) != "[]" {
+			t.Errorf("got commands %s, want []", body)
+		}
+
+		// The empty set is hashed like any other, so it isn't sent again.
+		if err := h.Bot.Reload(testGuildID); err != nil {
+			t.Fatalf("error reloading: %v", err)
+		}
+		if n := len(overwrites(h)); n != 1 {
+			t.Errorf("got %d overwrites after reloading, want 1", n)
+		}
+	})
+
+	t.Run("guild", func(t *testing.T) {
+		h := newTestHarness(t, Config{})
+		setCommand(t, h, "ping", models.CommandSettings{Disabled: true})
+		setCommand(t, h, "commands", models.CommandSettings{Alias: "cmds"})
+		if err := h.Bot.Reload(testGuildID); err != nil {
+			t.Fatalf("error reloading: %v", err)
+		}
+
+		got := overwrites(h)
+		if len(got) != 2 {
+			t.Fatalf("got %d overwrites, want 2", len(got))
+		}
+
+		var names []string
+		for _, cmd := range got[1] {
+			names = append(names, cmd.Name)
+		}
+		if slices.Contains(names, "ping") || !slices.Contains(names, "cmds") || slices.Contains(names, "commands") {
+			t.Errorf("got commands %q, want ping disabled and commands renamed to cmds", names)
+		}
+	})
+}
+
+func TestFailAfterAcknowledgement(t *testing.T) {
+	h := newTestHarness(t, Config{})
+
+	var failed atomic.Bool
+	h.Session.Err = func(c session.Call) error {
+		if c.Method == "FollowupMessageCreate" && failed.CompareAndSwap(false, true) {
+			return errors.New("unavailable")
+		}
+		return nil
+	}
+
+	i := h.Command(testGuildID, testUserID, "ping")
+	calls, err := h.Interact(i)
+	if err != nil {
+		t.Fatalf("error interacting: %v", err)
+	}
+
+	// The failure can't replace the deferred response, so it's sent as an
+	// ephemeral followup instead.
+	want := []string{"respond deferred", "followup Pong!", "respond message", "followup"}
+	if got := describe(calls); !slices.Equal(got, want) {
+		t.Fatalf("got calls %q, want %q", got, want)
+	}
+	if calls[3].Params.Flags&dg.MessageFlagsEphemeral == 0 {
+		t.Error("failure followup is not ephemeral")
+	}
+
+	if err := h.Flush(); err != nil {
+		t.Fatalf("error flushing: %v", err)
+	}
+	if n, _ := h.Store.Count(context.Background(), models.TableInteractions, sq.Eq{"id": i.ID, "outcome": models.OutcomeError}); n != 1 {
+		t.Errorf("got %d interactions recorded as errors, want 1", n)
+	}
+}
+
+func TestCloseFlushesAfterDeadline(t *testing.T) {
+	h := newTestHarness(t, Config{InteractionFlushInterval: time.Hour})
+
+	i := h.Command(testGuildID, testUserID, "ping")
+	if _, err := h.Interact(i); err != nil {
+		t.Fatalf("error interacting: %v", err)
+	}
+
+	// Waiting on in-flight handlers used up the shutdown deadline, but the
+	// buffered interaction still gets written.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := h.Bot.Close(ctx); err != nil {
+		t.Fatalf("error closing: %v", err)
+	}
+
+	if n, _ := h.Store.Count(context.Background(), models.TableInteractions, sq.Eq{"id": i.ID}); n != 1 {
+		t.Errorf("got %d stored interactions, want 1", n)
+	}
+}
+
+func TestUsageRollups(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHarness(t, Config{})
+	setCommand(t, h, "ping", models.CommandSettings{Alias: "pong"})
+
+	// Interactions from a week ago, already past a one day retention.
+	old := time.Now().UTC().AddDate(0, 0, -7)
+	var rows []models.Mappable
+	for _, userID := range []string{"3", "4", "4"} {
+		i := h.Command(testGuildID, userID, "pong")
+		rows = append(rows, models.Interaction{Interaction: i.Interaction, CommandName: "ping", Outcome: models.OutcomeOK, Created: old})
+	}
+	if err := h.Store.CreateMany(ctx, rows); err != nil {
+		t.Fatalf("error storing interactions: %v", err)
+	}
+
+	// Invoking the alias records the command's own name.
+	i := h.Command(testGuildID, testUserID, "pong")
+	if _, err := h.Interact(i); err != nil {
+		t.Fatalf("error interacting: %v", err)
+	}
+	if err := h.Flush(); err != nil {
+		t.Fatalf("error flushing: %v", err)
+	}
+	if n, _ := h.Store.Count(ctx, models.TableInteractions, sq.Eq{"id": i.ID, "command": "ping"}); n != 1 {
+		t.Errorf("got %d interactions stored as ping, want 1", n)
+	}
+
+	if err := h.Bot.Rollup(ctx); err != nil {
+		t.Fatalf("error rolling up: %v", err)
+	}
+	if _, err := h.Store.PurgeInteractions(ctx, time.Now().UTC(), 1, 100, func([]models.ArchivedInteraction) error { return nil }); err != nil {
+		t.Fatalf("error purging: %v", err)
+	}
+	if n, _ := h.Store.Count(ctx, models.TableInteractions, nil); n != 1 {
+		t.Fatalf("got %d interactions after purging, want 1", n)
+	}
+
+	// Everything below comes from the rollups, so the purged interactions
+	// are still counted.
+	since := old.AddDate(0, 0, -1)
+	usage, err := h.Store.CommandUsage(ctx, testGuildID, since)
+	if err != nil {
+		t.Fatalf("error getting command usage: %v", err)
+	}
+	if len(usage) != 1 || usage[0].Command != "ping" || usage[0].Uses != 4 {
+		t.Errorf("got command usage %+v, want 4 uses of ping", usage)
+	}
+
+	users, err := h.Store.UniqueUsers(ctx, testGuildID, since)
+	if err != nil {
+		t.Fatalf("error counting users: %v", err)
+	}
+	if users != 3 {
+		t.Errorf("got %d unique users, want 3", users)
+	}
+
+	total, err := h.Store.TotalInteractions(ctx)
+	if err != nil {
+		t.Fatalf("error counting interactions: %v", err)
+	}
+	if total != 4 {
+		t.Errorf("got %d total interactions, want 4", total)
+	}
+
+	// Today is rolled up again on every run, so until then its new
+	// interactions are counted from the raw table.
+	if _, err := h.Interact(h.Command(testGuildID, testUserID, "pong")); err != nil {
+		t.Fatalf("error interacting: %v", err)
+	}
+	if err := h.Flush(); err != nil {
+		t.Fatalf("error flushing: %v", err)
+	}
+	if total, _ := h.Store.TotalInteractions(ctx); total != 5 {
+		t.Errorf("got %d total interactions before rolling up again, want 5", total)
+	}
+}
+
+func TestVoiceUpdates(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHarness(t, Config{})
+
+	states := []*dg.VoiceState{
+		{GuildID: testGuildID, UserID: "3", ChannelID: "10"},
+		{GuildID: testGuildID, UserID: "3", ChannelID: "10", SelfMute: true},
+		{GuildID: testGuildID, UserID: "3", ChannelID: "11", SelfMute: true},
+		{GuildID: testGuildID, UserID: "3"},
+	}
+	for _, v := range states {
+		if err := h.VoiceUpdate(v); err != nil {
+			t.Fatalf("error updating voice state: %v", err)
+		}
+	}
+
+	for kind, want := range map[string]int{models.VoiceJoin: 1, models.VoiceMute: 1, models.VoiceMove: 1, models.VoiceLeave: 1} {
+		if n, _ := h.Store.Count(ctx, models.TableVoiceEvents, sq.Eq{"type": kind}); n != want {
+			t.Errorf("got %d %s events, want %d", n, kind, want)
+		}
+	}
+	if n, _ := h.Store.Count(ctx, models.TableVoiceSessions, nil); n != 2 {
+		t.Errorf("got %d sessions, want 2", n)
+	}
+	if n, _ := h.Store.Count(ctx, models.TableVoiceSessions, sq.Eq{"ended": nil}); n != 0 {
+		t.Errorf("got %d open sessions, want 0", n)
+	}
+
+	// A voice update stuck on its write doesn't hold up interactions.
+	release := make(chan struct{})
+	defer close(release)
+	h.Bot.enqueue(testGuildID, GuildEvent{
+		Type:        EventTypeVoiceUpdate,
+		VoiceUpdate: &dg.VoiceStateUpdate{VoiceState: &dg.VoiceState{GuildID: testGuildID, UserID: "3", ChannelID: "10"}},
+		done:        func() { <-release },
+	})
+
+	calls, err := h.Interact(h.Command(testGuildID, testUserID, "ping"))
+	if err != nil {
+		t.Fatalf("error interacting: %v", err)
+	}
+	if got, want := describe(calls), []string{"respond deferred", "followup Pong!"}; !slices.Equal(got, want) {
+		t.Errorf("got calls %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,259 @@
+package bot
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/glotchimo/recast/internal/database"
+	"github.com/glotchimo/recast/internal/handlers"
+	"github.com/glotchimo/recast/internal/models"
+	"github.com/glotchimo/recast/internal/utils"
+	"github.com/graxinc/errutil"
+)
+
+type RegistrationMode string
+
+const (
+	RegisterGuild  RegistrationMode = "guild"
+	RegisterGlobal RegistrationMode = "global"
+	RegisterDev    RegistrationMode = "dev"
+)
+
+// commandKey identifies a handler. Chat commands and context menu commands
+// have separate namespaces, so a name alone isn't unique.
+type commandKey struct {
+	Type dg.ApplicationCommandType
+	Name string
+}
+
+func key(t dg.ApplicationCommandType, name string) commandKey {
+	if t == 0 {
+		t = dg.ChatApplicationCommand
+	}
+	return commandKey{Type: t, Name: name}
+}
+
+func index(hs ...handlers.Handler) map[commandKey]handlers.Handler {
+	m := make(map[commandKey]handlers.Handler, len(hs))
+	for _, h := range hs {
+		cmd := h.Metadata()
+		k := key(cmd.Type, cmd.Name)
+		if _, ok := m[k]; ok {
+			panic(fmt.Sprintf("duplicate handler for command %q of type %d", k.Name, k.Type))
+		}
+		m[k] = h
+	}
+	return m
+}
+
+func (b *Bot) commandSet(include func(handlers.Handler) bool) []*dg.ApplicationCommand {
+	var commands []*dg.ApplicationCommand
+
+	for _, h := range lookup {
+		if !include(h) {
+			continue
+		}
+
+		cmd := h.Metadata()
+		if r, ok := h.(handlers.Restricted); ok && cmd.DefaultMemberPermissions == nil {
+			if perms := r.Requirements().Permissions; perms != 0 {
+				cmd.DefaultMemberPermissions = &perms
+			}
+		}
+		commands = append(commands, &cmd)
+	}
+
+	for i, cmd := range commands {
+		result := utils.ValidateCommand(cmd)
+		if result.WasModified {
+			commands[i] = result.Command
+			b.l.Warn("command was modified during validation", "command", cmd.Name, "errors", result.Errors)
+		}
+	}
+
+	slices.SortFunc(commands, func(a, b *dg.ApplicationCommand) int {
+		if c := strings.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return int(a.Type) - int(b.Type)
+	})
+
+	return commands
+}
+
+func hashCommands(commands []*dg.ApplicationCommand) string {
+	bytes, err := json.Marshal(commands)
+	if err != nil {
+		return ""
+	}
+
+	hash := sha256.Sum256(bytes)
+	return fmt.Sprintf("%x", hash)
+}
+
+func scopedTo(h handlers.Handler, guildID string) bool {
+	s, ok := h.(handlers.Scoped)
+	return ok && slices.Contains(s.Guilds(), guildID)
+}
+
+func (b *Bot) guildCommands(g *models.Guild) []*dg.ApplicationCommand {
+	guildID := g.ID
+
+	var commands []*dg.ApplicationCommand
+	switch b.conf.Registration {
+	case RegisterGlobal:
+		commands = b.commandSet(func(h handlers.Handler) bool {
+			return scopedTo(h, guildID)
+		})
+	case RegisterDev:
+		commands = b.commandSet(func(h handlers.Handler) bool {
+			return true
+		})
+	default:
+		commands = b.commandSet(func(h handlers.Handler) bool {
+			_, scoped := h.(handlers.Scoped)
+			return !scoped || scopedTo(h, guildID)
+		})
+	}
+
+	// An empty set must still be sent as an empty array, since that's what
+	// clears commands left over from an earlier registration.
+	filtered := make([]*dg.ApplicationCommand, 0, len(commands))
+	for _, cmd := range commands {
+		if !g.CommandEnabled(cmd.Name) {
+			continue
+		}
+		if alias := g.Settings.Commands[cmd.Name].Alias; alias != "" {
+			cmd.Name = alias
+		}
+		filtered = append(filtered, cmd)
+	}
+
+	return filtered
+}
+
+func (b *Bot) Commands() []dg.ApplicationCommand {
+	var commands []dg.ApplicationCommand
+	for _, h := range lookup {
+		commands = append(commands, h.Metadata())
+	}
+
+	slices.SortFunc(commands, func(a, b dg.ApplicationCommand) int {
+		if c := strings.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return int(a.Type) - int(b.Type)
+	})
+
+	return commands
+}
+
+// Overridable reports whether a guild's settings for a command are applied,
+// which needs the command to be registered per guild.
+func (b *Bot) Overridable(guildID, name string) bool {
+	switch b.conf.Registration {
+	case RegisterGlobal:
+		// Settings are keyed by name, so they apply to every handler sharing it.
+		found := false
+		for k, h := range lookup {
+			if k.Name != name {
+				continue
+			}
+			if !scopedTo(h, guildID) {
+				return false
+			}
+			found = true
+		}
+		return found
+	case RegisterDev:
+		return guildID == b.conf.DevGuildID
+	default:
+		return true
+	}
+}
+
+func (b *Bot) Reload(guildID string) error {
+	return b.load(guildID)
+}
+
+func (b *Bot) load(guildID string) error {
+	b.ensure(guildID)
+
+	if b.conf.Registration == RegisterDev && guildID != b.conf.DevGuildID {
+		return nil
+	}
+
+	start := time.Now()
+
+	g, err := b.d.GetGuild(b.ctx, guildID)
+	if err != nil {
+		return errutil.With(err)
+	}
+
+	commands := b.guildCommands(g)
+
+	newHash := hashCommands(commands)
+	oldHash := g.Settings.CommandSetHash
+	if newHash == oldHash {
+		b.l.Info("command set unchanged", "guild", guildID)
+		return nil
+	}
+
+	if _, err := b.s.ApplicationCommandBulkOverwrite(b.s.State().User.ID, guildID, commands); err != nil {
+		return errutil.With(err)
+	}
+
+	if err := b.d.Update(b.ctx, models.TableGuilds, sq.Eq{"id": guildID}, map[string]any{
+		"settings": database.SetJSON("settings", newHash, "command_set_hash"),
+	}); err != nil {
+		b.l.Warn("error updating command set hash", "error", err, "guild", guildID, "hash", newHash)
+	}
+
+	b.l.Info("command set loaded", "loaded", len(commands), "guild", guildID, "duration", time.Since(start))
+	return nil
+}
+
+func (b *Bot) loadGlobal() {
+	start := time.Now()
+	appID := b.s.State().User.ID
+
+	stored, err := b.d.GetBot(b.ctx, appID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			b.l.Error("error getting bot settings", "error", err)
+			return
+		}
+		stored = &models.Bot{ID: appID}
+	}
+
+	commands := b.commandSet(func(h handlers.Handler) bool {
+		_, scoped := h.(handlers.Scoped)
+		return !scoped
+	})
+
+	newHash := hashCommands(commands)
+	if newHash == stored.Settings.CommandSetHash {
+		b.l.Info("global command set unchanged")
+		return
+	}
+
+	if _, err := b.s.ApplicationCommandBulkOverwrite(appID, "", commands); err != nil {
+		b.l.Error("error loading global commands", "error", err)
+		return
+	}
+
+	stored.Settings.CommandSetHash = newHash
+	if err := b.d.PutBot(b.ctx, *stored); err != nil {
+		b.l.Warn("error updating global command set hash", "error", err, "hash", newHash)
+	}
+
+	b.l.Info("global command set loaded", "loaded", len(commands), "duration", time.Since(start))
+}
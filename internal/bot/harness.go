@@ -0,0 +1,196 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/glotchimo/recast/internal/cache"
+	"github.com/glotchimo/recast/internal/database"
+	"github.com/glotchimo/recast/internal/response"
+	"github.com/glotchimo/recast/internal/session"
+	"github.com/graxinc/errutil"
+)
+
+const (
+	harnessBotID   = "100000000000000000"
+	harnessTimeout = 5 * time.Second
+)
+
+type Harness struct {
+	Bot     *Bot
+	Session *session.Fake
+	Store   *database.Memory
+	Cache   *cache.Cache
+	Timeout time.Duration
+
+	ids atomic.Int64
+}
+
+func NewHarness(conf Config) *Harness {
+	if conf.Registration == "" {
+		conf.Registration = RegisterGuild
+	}
+
+	b := newBot(conf)
+	if !conf.Debug {
+		b.l = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	fake := session.NewFake(harnessBotID)
+	store := database.NewMemory()
+
+	b.s = fake
+	b.d = store
+	b.w = newInteractionWriter(b.l, store, conf)
+	b.c = cache.NewLocal(b.l)
+	b.r = response.NewSessionResponder(b.s, b.l, b.d, b.ctx)
+	b.r.OnFail(b.relayFailure)
+
+	return &Harness{
+		Bot:     b,
+		Session: fake,
+		Store:   store,
+		Cache:   b.c,
+		Timeout: harnessTimeout,
+	}
+}
+
+func (h *Harness) AddGuild(g *dg.Guild) error {
+	if !h.Bot.track(g) {
+		return fmt.Errorf("error registering guild %s", g.ID)
+	}
+
+	if err := h.Bot.load(g.ID); err != nil {
+		return errutil.With(err)
+	}
+
+	go h.Bot.dispatch(g.ID)
+	h.Bot.reconcileVoice(g)
+	return nil
+}
+
+func (h *Harness) Interact(i *dg.InteractionCreate) ([]session.Call, error) {
+	before := len(h.Session.Calls())
+
+	done := make(chan struct{})
+	h.Bot.enqueue(i.GuildID, GuildEvent{
+		Type:        EventTypeInteraction,
+		Interaction: i,
+		done:        func() { close(done) },
+	})
+
+	select {
+	case <-done:
+	case <-time.After(h.Timeout):
+		return nil, fmt.Errorf("interaction %s was not dispatched within %s", i.ID, h.Timeout)
+	}
+
+	h.Bot.inflight.Wait()
+
+	return h.Session.Calls()[before:], nil
+}
+
+// VoiceUpdate queues a voice state change and waits for it to be recorded.
+func (h *Harness) VoiceUpdate(v *dg.VoiceState) error {
+	done := make(chan struct{})
+	h.Bot.enqueue(v.GuildID, GuildEvent{
+		Type:        EventTypeVoiceUpdate,
+		VoiceUpdate: &dg.VoiceStateUpdate{VoiceState: v},
+		done:        func() { close(done) },
+	})
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(h.Timeout):
+		return fmt.Errorf("voice update for %s was not recorded within %s", v.UserID, h.Timeout)
+	}
+}
+
+func (h *Harness) Flush() error {
+	ctx, cancel := context.WithTimeout(context.Background(), h.Timeout)
+	defer cancel()
+
+	return h.Bot.w.Flush(ctx)
+}
+
+func (h *Harness) Command(guildID, userID, name string, options ...*dg.ApplicationCommandInteractionDataOption) *dg.InteractionCreate {
+	return h.interaction(dg.InteractionApplicationCommand, guildID, userID, dg.ApplicationCommandInteractionData{
+		Name:        name,
+		CommandType: dg.ChatApplicationCommand,
+		Options:     options,
+	})
+}
+
+// UserCommand builds a user context menu interaction targeting target. A nil
+// target leaves the interaction without resolved data.
+func (h *Harness) UserCommand(guildID, userID, name string, target *dg.User) *dg.InteractionCreate {
+	data := dg.ApplicationCommandInteractionData{
+		Name:        name,
+		CommandType: dg.UserApplicationCommand,
+	}
+	if target != nil {
+		data.TargetID = target.ID
+		data.Resolved = &dg.ApplicationCommandInteractionDataResolved{
+			Users:   map[string]*dg.User{target.ID: target},
+			Members: map[string]*dg.Member{target.ID: {GuildID: guildID}},
+		}
+	}
+
+	return h.interaction(dg.InteractionApplicationCommand, guildID, userID, data)
+}
+
+func (h *Harness) Autocomplete(guildID, userID, name string, options ...*dg.ApplicationCommandInteractionDataOption) *dg.InteractionCreate {
+	return h.interaction(dg.InteractionApplicationCommandAutocomplete, guildID, userID, dg.ApplicationCommandInteractionData{
+		Name:        name,
+		CommandType: dg.ChatApplicationCommand,
+		Options:     options,
+	})
+}
+
+func (h *Harness) Component(guildID, userID, customID string, values ...string) *dg.InteractionCreate {
+	return h.interaction(dg.InteractionMessageComponent, guildID, userID, dg.MessageComponentInteractionData{
+		CustomID:      customID,
+		ComponentType: dg.ButtonComponent,
+		Values:        values,
+	})
+}
+
+func (h *Harness) Modal(guildID, userID, customID string, components ...dg.MessageComponent) *dg.InteractionCreate {
+	return h.interaction(dg.InteractionModalSubmit, guildID, userID, dg.ModalSubmitInteractionData{
+		CustomID:   customID,
+		Components: components,
+	})
+}
+
+func (h *Harness) interaction(t dg.InteractionType, guildID, userID string, data dg.InteractionData) *dg.InteractionCreate {
+	id := fmt.Sprintf("%d", h.ids.Add(1))
+
+	return &dg.InteractionCreate{
+		Interaction: &dg.Interaction{
+			ID:        id,
+			AppID:     harnessBotID,
+			Type:      t,
+			Data:      data,
+			GuildID:   guildID,
+			ChannelID: guildID,
+			Token:     "token-" + id,
+			Member: &dg.Member{
+				GuildID: guildID,
+				User:    &dg.User{ID: userID, Username: "user-" + userID},
+			},
+		},
+	}
+}
+
+func (h *Harness) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), h.Timeout)
+	defer cancel()
+
+	return h.Bot.Close(ctx)
+}
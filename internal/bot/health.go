@@ -0,0 +1,147 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/glotchimo/recast/internal/cache"
+)
+
+const heartbeatStaleAfter = 2 * time.Minute
+
+type Check struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type Readiness struct {
+	Ready  bool    `json:"ready"`
+	Checks []Check `json:"checks"`
+}
+
+type ShardStatus struct {
+	ShardID       int       `json:"shard_id"`
+	ShardCount    int       `json:"shard_count"`
+	Connected     bool      `json:"connected"`
+	Guilds        int       `json:"guilds"`
+	LatencyMillis int64     `json:"latency_ms"`
+	LastHeartbeat time.Time `json:"last_heartbeat"`
+}
+
+type GuildStats struct {
+	Events           int   `json:"events"`
+	EventsCapacity   int   `json:"events_capacity"`
+	Messages         int   `json:"messages"`
+	MessagesCapacity int   `json:"messages_capacity"`
+	Voice            int   `json:"voice"`
+	VoiceCapacity    int   `json:"voice_capacity"`
+	Relay            int   `json:"relay"`
+	RelayCapacity    int   `json:"relay_capacity"`
+	Goroutines       int64 `json:"goroutines"`
+}
+
+func (b *Bot) GuildStats() map[string]GuildStats {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	stats := make(map[string]GuildStats, len(b.contexts))
+	for id, gc := range b.contexts {
+		stats[id] = GuildStats{
+			Events:           len(gc.Events),
+			EventsCapacity:   cap(gc.Events),
+			Messages:         len(gc.Messages),
+			MessagesCapacity: cap(gc.Messages),
+			Voice:            len(gc.Voice),
+			VoiceCapacity:    cap(gc.Voice),
+			Relay:            len(gc.Relay),
+			RelayCapacity:    cap(gc.Relay),
+			Goroutines:       gc.Goroutines.Load(),
+		}
+	}
+
+	return stats
+}
+
+func (b *Bot) Readiness(ctx context.Context) Readiness {
+	checks := []Check{
+		b.checkShutdown(),
+		b.checkGateway(),
+		b.checkDatabase(ctx),
+		b.checkCache(),
+	}
+
+	ready := true
+	for _, c := range checks {
+		ready = ready && c.OK
+	}
+
+	return Readiness{Ready: ready, Checks: checks}
+}
+
+func (b *Bot) Shards() []ShardStatus {
+	b.gw.RLock()
+	connected := b.gw.DataReady
+	lastAck := b.gw.LastHeartbeatAck
+	b.gw.RUnlock()
+
+	guilds := 0
+	if b.gw.State != nil {
+		b.gw.State.RLock()
+		guilds = len(b.gw.State.Guilds)
+		b.gw.State.RUnlock()
+	}
+
+	return []ShardStatus{
+		{
+			ShardID:       b.gw.ShardID,
+			ShardCount:    b.gw.ShardCount,
+			Connected:     connected,
+			Guilds:        guilds,
+			LatencyMillis: b.gw.HeartbeatLatency().Milliseconds(),
+			LastHeartbeat: lastAck,
+		},
+	}
+}
+
+func (b *Bot) checkShutdown() Check {
+	b.mu.RLock()
+	closing := b.closing
+	b.mu.RUnlock()
+
+	if closing {
+		return Check{Name: "accepting", OK: false, Detail: "shutting down"}
+	}
+	return Check{Name: "accepting", OK: true}
+}
+
+func (b *Bot) checkGateway() Check {
+	for _, shard := range b.Shards() {
+		if !shard.Connected {
+			return Check{Name: "gateway", OK: false, Detail: fmt.Sprintf("shard %d disconnected", shard.ShardID)}
+		}
+		if age := time.Since(shard.LastHeartbeat); age > heartbeatStaleAfter {
+			return Check{Name: "gateway", OK: false, Detail: fmt.Sprintf("shard %d last heartbeat %s ago", shard.ShardID, age.Round(time.Second))}
+		}
+	}
+	return Check{Name: "gateway", OK: true}
+}
+
+func (b *Bot) checkDatabase(ctx context.Context) Check {
+	// The error can carry connection details, and readiness is served
+	// unauthenticated, so it only goes to the logs.
+	if err := b.d.Ping(ctx); err != nil {
+		b.l.Warn("database readiness check failed", "error", err)
+		return Check{Name: "database", OK: false, Detail: "unreachable"}
+	}
+	return Check{Name: "database", OK: true}
+}
+
+func (b *Bot) checkCache() Check {
+	state, failures, lastFailure := b.c.Breaker().Stats()
+	if state == cache.StateOpen {
+		return Check{Name: "cache", OK: false, Detail: fmt.Sprintf("circuit breaker %s after %d failures, last at %s", state, failures, lastFailure.Format(time.RFC3339))}
+	}
+	return Check{Name: "cache", OK: true, Detail: fmt.Sprintf("circuit breaker %s", state)}
+}
@@ -0,0 +1,184 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strings"
+
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/glotchimo/recast/internal/models"
+	"github.com/glotchimo/recast/internal/utils"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func messageIndex(guildID string) string {
+	return fmt.Sprintf("messages:%s", guildID)
+}
+
+func messageKey(guildID, messageID string) string {
+	return fmt.Sprintf("message:%s:%s", guildID, messageID)
+}
+
+// messages runs message events on their own queue so a busy channel can't
+// crowd out or delay interactions.
+func (b *Bot) messages(guildID string, ctx *GuildContext) {
+	ctx.Goroutines.Add(1)
+	defer ctx.Goroutines.Add(-1)
+
+	for {
+		select {
+		case <-ctx.Context.Done():
+			return
+		case e := <-ctx.Messages:
+			b.message(guildID, e)
+		}
+	}
+}
+
+func (b *Bot) message(guildID string, e GuildEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			stack := make([]byte, 4096)
+			stack = stack[:runtime.Stack(stack, false)]
+			b.l.Error("panic recovered", "guild", guildID, "event", e.Type, "recovered", r, "stack", stack)
+		}
+
+		trace.SpanFromContext(e.ctx).End()
+		if e.done != nil {
+			e.done()
+		}
+	}()
+
+	switch e.Type {
+	case EventTypeMsgCreation:
+		b.cacheMessage(e.ctx, guildID, e.MsgCreation.Message)
+	case EventTypeMsgUpdate:
+		b.updateMessage(e.ctx, guildID, e.MsgUpdate.Message)
+	case EventTypeMsgDeletion:
+		b.deleteMessage(e.ctx, guildID, e.MsgDeletion)
+	}
+}
+
+func (b *Bot) cacheMessage(ctx context.Context, guildID string, m *dg.Message) {
+	if b.conf.MessageCacheSize <= 0 || m.Author == nil || m.Author.ID == b.s.State().User.ID {
+		return
+	}
+
+	b.putMessage(ctx, guildID, models.NewMessage(m))
+}
+
+func (b *Bot) updateMessage(ctx context.Context, guildID string, m *dg.Message) {
+	msg, ok := b.getMessage(ctx, guildID, m.ID)
+	if !ok {
+		b.cacheMessage(ctx, guildID, m)
+		return
+	}
+
+	msg.Apply(m)
+	b.putMessage(ctx, guildID, msg)
+}
+
+func (b *Bot) deleteMessage(ctx context.Context, guildID string, d *dg.MessageDelete) {
+	if b.conf.MessageCacheSize <= 0 {
+		return
+	}
+
+	msg, ok := b.getMessage(ctx, guildID, d.ID)
+	if ok {
+		if err := b.c.Remove(ctx, messageIndex(guildID), messageKey(guildID, d.ID)); err != nil {
+			b.l.Warn("error removing cached message", "error", err, "guild", guildID, "message", d.ID)
+		}
+	} else if d.BeforeDelete != nil && d.BeforeDelete.Author != nil {
+		msg, ok = models.NewMessage(d.BeforeDelete), true
+	}
+
+	if !ok || msg.AuthorID == b.s.State().User.ID {
+		return
+	}
+
+	g, err := b.d.GetGuild(ctx, guildID)
+	if err != nil {
+		b.l.Warn("error fetching guild for deleted message", "error", err, "guild", guildID, "message", d.ID)
+		return
+	}
+	if g.Settings.LogChannelID == "" || g.Settings.LogChannelID == msg.ChannelID {
+		return
+	}
+
+	b.Relay(guildID, formatDeletion(msg))
+}
+
+func (b *Bot) putMessage(ctx context.Context, guildID string, msg models.Message) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		b.l.Warn("error encoding message", "error", err, "guild", guildID, "message", msg.ID)
+		return
+	}
+
+	if err := b.c.Push(ctx, messageIndex(guildID), messageKey(guildID, msg.ID), data, b.conf.MessageCacheSize, b.conf.MessageCacheTTL); err != nil {
+		b.l.Warn("error caching message", "error", err, "guild", guildID, "message", msg.ID)
+	}
+}
+
+func (b *Bot) getMessage(ctx context.Context, guildID, messageID string) (models.Message, bool) {
+	var msg models.Message
+
+	data, err := b.c.Get(ctx, messageKey(guildID, messageID))
+	if err != nil {
+		return msg, false
+	}
+
+	if err := json.Unmarshal(data, &msg); err != nil {
+		b.l.Warn("error decoding cached message", "error", err, "guild", guildID, "message", messageID)
+		return msg, false
+	}
+
+	return msg, true
+}
+
+// formatDeletion builds a relay line for a deleted message. Relay cuts lines
+// at relayLineLimit bytes, so attachment links are kept whole and the quoted
+// content gets whatever room is left.
+func formatDeletion(msg models.Message) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Message by %s (%s) deleted in <#%s>\n", utils.FormatUserMention(msg.AuthorID), msg.AuthorName, msg.ChannelID)
+	fmt.Fprintf(&b, "Sent %s", utils.FormatTimestamp(msg.Created, utils.TimestampShortDateTime))
+	if msg.Edited != nil {
+		fmt.Fprintf(&b, ", edited %s", utils.FormatTimestamp(*msg.Edited, utils.TimestampShortDateTime))
+	}
+
+	// Room is kept for a note about the links that don't fit.
+	var attachments strings.Builder
+	more := func(n int) string { return fmt.Sprintf("\n…and %d more", len(msg.Attachments)-n) }
+	for n, a := range msg.Attachments {
+		link := fmt.Sprintf("\n[%s](%s) (%d bytes)", a.Filename, a.URL, a.Size)
+		reserved := 0
+		if n < len(msg.Attachments)-1 {
+			reserved = len(more(n + 1))
+		}
+		if b.Len()+attachments.Len()+len(link)+reserved > relayLineLimit {
+			attachments.WriteString(more(n))
+			break
+		}
+		attachments.WriteString(link)
+	}
+
+	if msg.Content != "" {
+		content := "\n> " + strings.ReplaceAll(msg.Content, "\n", "\n> ")
+		if budget := relayLineLimit - b.Len() - attachments.Len(); len(content) > budget {
+			content = strings.ToValidUTF8(content[:max(budget-len("…"), 0)], "")
+			if len(content) > len("\n> ") {
+				content += "…"
+			} else {
+				content = ""
+			}
+		}
+		b.WriteString(content)
+	}
+
+	b.WriteString(attachments.String())
+	return b.String()
+}
@@ -0,0 +1,65 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/glotchimo/recast/internal/models"
+)
+
+func TestFormatDeletion(t *testing.T) {
+	attachment := func(n int) models.Attachment {
+		name := fmt.Sprintf("file-%d.png", n)
+		return models.Attachment{
+			Filename: name,
+			URL:      "https://cdn.discordapp.com/attachments/1/2/" + name + "?ex=" + strings.Repeat("a", 150),
+			Size:     1024,
+		}
+	}
+	link := func(a models.Attachment) string { return fmt.Sprintf("[%s](%s) (%d bytes)", a.Filename, a.URL, a.Size) }
+
+	tests := []struct {
+		name        string
+		content     string
+		attachments int
+		shown       int
+		truncated   bool
+	}{
+		{name: "short", content: "hello\nworld", attachments: 1, shown: 1},
+		{name: "long content", content: strings.Repeat("é", 1000), truncated: true},
+		{name: "long content with attachments", content: strings.Repeat("a", 1000), attachments: 2, shown: 2, truncated: true},
+		{name: "too many attachments", content: "hello", attachments: 10, shown: 3, truncated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := models.Message{ID: "1", ChannelID: "2", AuthorID: "3", AuthorName: "user", Content: tt.content, Created: time.Now()}
+			for n := range tt.attachments {
+				msg.Attachments = append(msg.Attachments, attachment(n))
+			}
+
+			got := formatDeletion(msg)
+			if len(got) > relayLineLimit {
+				t.Errorf("got %d bytes, want at most %d", len(got), relayLineLimit)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("got invalid UTF-8 %q", got)
+			}
+			if strings.Contains(got, "…") != tt.truncated {
+				t.Errorf("got %q, want truncated %t", got, tt.truncated)
+			}
+
+			for n, a := range msg.Attachments {
+				if shown := strings.Contains(got, "\n"+link(a)); shown != (n < tt.shown) {
+					t.Errorf("got attachment %d shown %t, want %t", n, shown, n < tt.shown)
+				}
+			}
+			if more := fmt.Sprintf("…and %d more", tt.attachments-tt.shown); tt.shown < tt.attachments && !strings.HasSuffix(got, more) {
+				t.Errorf("got %q, want it to end with %q", got, more)
+			}
+		})
+	}
+}
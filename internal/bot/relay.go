@@ -0,0 +1,156 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/glotchimo/recast/internal/metrics"
+	"github.com/glotchimo/recast/internal/models"
+	"github.com/glotchimo/recast/internal/utils"
+)
+
+const (
+	relayFlushInterval = 2 * time.Second
+	relayLineLimit     = 1000
+	relayEmbedLimit    = 4000
+	relayMessageLimit  = 5800
+	relayMaxEmbeds     = 10
+	relayColor         = 0x5865F2
+)
+
+func (b *Bot) Relay(guildID, message string) {
+	b.mu.RLock()
+	gc, ok := b.contexts[guildID]
+	b.mu.RUnlock()
+	if !ok {
+		metrics.RelayMessages.WithLabelValues("dropped").Inc()
+		return
+	}
+
+	if len(message) > relayLineLimit {
+		message = strings.ToValidUTF8(message[:relayLineLimit], "") + "…"
+	}
+	line := fmt.Sprintf("%s %s", utils.FormatTimestamp(time.Now(), utils.TimestampLong), message)
+
+	select {
+	case gc.Relay <- line:
+	default:
+		metrics.RelayMessages.WithLabelValues("dropped").Inc()
+		b.l.Warn("relay queue full, dropped message", "guild", guildID)
+	}
+}
+
+func (b *Bot) relayFailure(i *dg.InteractionCreate, f utils.Failure) {
+	if i.GuildID == "" {
+		return
+	}
+
+	// Error details stay in the server logs; the log channel is visible to
+	// guild staff.
+	interaction := models.Interaction{Interaction: i.Interaction}
+	message := fmt.Sprintf("`%s` failed for %s (%s): %s", interaction.Command(), utils.FormatUserMention(interaction.UserID()), f.Type, f.Message)
+	b.Relay(i.GuildID, message)
+}
+
+func (b *Bot) relay(guildID string, ctx *GuildContext) {
+	ctx.Goroutines.Add(1)
+	defer ctx.Goroutines.Add(-1)
+
+	ticker := time.NewTicker(relayFlushInterval)
+	defer ticker.Stop()
+
+	var pending []string
+	var retryAt time.Time
+	for {
+		select {
+		case <-ctx.Context.Done():
+			return
+		case line := <-ctx.Relay:
+			pending = append(pending, line)
+			if over := len(pending) - relayQueueSize; over > 0 {
+				pending = pending[over:]
+				metrics.RelayMessages.WithLabelValues("dropped").Add(float64(over))
+			}
+		case <-ticker.C:
+			if len(pending) == 0 || time.Now().Before(retryAt) {
+				continue
+			}
+
+			n, wait := b.post(ctx.Context, guildID, pending)
+			pending = pending[n:]
+			retryAt = time.Now().Add(wait)
+		}
+	}
+}
+
+// post sends at most one message per call, so a busy guild is held to one
+// message per flush interval, well under Discord's per-channel limit.
+func (b *Bot) post(ctx context.Context, guildID string, lines []string) (int, time.Duration) {
+	g, err := b.d.GetGuild(ctx, guildID)
+	if err != nil {
+		b.l.Warn("error fetching guild for relay", "error", err, "guild", guildID)
+		return 0, relayFlushInterval
+	}
+
+	channelID := g.Settings.LogChannelID
+	if channelID == "" {
+		metrics.RelayMessages.WithLabelValues("discarded").Add(float64(len(lines)))
+		return len(lines), 0
+	}
+
+	embeds, n := relayEmbeds(lines)
+	if _, err := b.s.ChannelMessageSendComplex(channelID, &dg.MessageSend{Embeds: embeds}); err != nil {
+		var rl *dg.RateLimitError
+		if errors.As(err, &rl) {
+			b.l.Warn("rate limited posting to log channel", "guild", guildID, "channel", channelID, "retry_after", rl.RetryAfter)
+			return 0, rl.RetryAfter
+		}
+
+		metrics.RelayMessages.WithLabelValues("failed").Add(float64(n))
+		b.l.Warn("error posting to log channel", "error", err, "guild", guildID, "channel", channelID)
+		return n, 0
+	}
+
+	metrics.RelayMessages.WithLabelValues("sent").Add(float64(n))
+	return n, 0
+}
+
+func relayEmbeds(lines []string) ([]*dg.MessageEmbed, int) {
+	var embeds []*dg.MessageEmbed
+	var b strings.Builder
+	var total, n int
+
+	flush := func() {
+		if b.Len() > 0 {
+			embeds = append(embeds, &dg.MessageEmbed{Description: b.String(), Color: relayColor})
+			b.Reset()
+		}
+	}
+
+	for _, line := range lines {
+		size := len(line) + 1
+		if total+size > relayMessageLimit {
+			break
+		}
+		if b.Len()+size > relayEmbedLimit {
+			flush()
+			if len(embeds) == relayMaxEmbeds {
+				break
+			}
+		}
+
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(line)
+		total += size
+		n++
+	}
+	flush()
+
+	return embeds, n
+}
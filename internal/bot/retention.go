@@ -0,0 +1,156 @@
+package bot
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/glotchimo/recast/internal/models"
+	"github.com/graxinc/errutil"
+)
+
+const retentionBatchSize = 1000
+
+type Retention struct {
+	Days       int
+	Interval   time.Duration
+	ArchiveDir string
+}
+
+func (b *Bot) retain() {
+	if b.conf.Retention.Interval <= 0 || b.conf.ShardID != 0 {
+		return
+	}
+
+	ticker := time.NewTicker(b.conf.Retention.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := b.Purge(b.ctx); err != nil {
+				b.l.Error("error purging expired interactions", "error", err)
+			}
+			if _, err := b.PurgeVoice(b.ctx); err != nil {
+				b.l.Error("error purging expired voice events", "error", err)
+			}
+		}
+	}
+}
+
+// Purge archives each batch to a temporary file that is only moved into place
+// once the batch's delete has committed, so a failed commit can't leave rows
+// both archived and in the table.
+func (b *Bot) Purge(ctx context.Context) (int, error) {
+	start := time.Now()
+	now := start.UTC()
+
+	var total int
+	for {
+		var tmp string
+		n, err := b.d.PurgeInteractions(ctx, now, b.conf.Retention.Days, retentionBatchSize, func(rows []models.ArchivedInteraction) error {
+			path, err := b.archive(rows)
+			if err != nil {
+				return errutil.With(err)
+			}
+			tmp = path
+			return nil
+		})
+		if err != nil {
+			if tmp != "" {
+				os.Remove(tmp)
+			}
+			return total, errutil.With(err)
+		}
+
+		if tmp != "" {
+			path := filepath.Join(b.conf.Retention.ArchiveDir, fmt.Sprintf("interactions-%s-%06d.jsonl.gz", now.Format("20060102T150405Z"), total/retentionBatchSize))
+			if err := os.Rename(tmp, path); err != nil {
+				return total, errutil.With(err)
+			}
+			b.l.Info("archived interactions", "rows", n, "path", path)
+		}
+
+		total += n
+		if n < retentionBatchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		b.l.Info("purged expired interactions", "rows", total, "duration", time.Since(start))
+	}
+
+	return total, nil
+}
+
+// PurgeVoice deletes voice events past the retention window. Sessions are
+// kept, so voice totals outlive the events they were built from.
+func (b *Bot) PurgeVoice(ctx context.Context) (int, error) {
+	start := time.Now()
+	now := start.UTC()
+
+	var total int
+	for {
+		n, err := b.d.PurgeVoiceEvents(ctx, now, b.conf.Retention.Days, retentionBatchSize)
+		if err != nil {
+			return total, errutil.With(err)
+		}
+
+		total += n
+		if n < retentionBatchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		b.l.Info("purged expired voice events", "rows", total, "duration", time.Since(start))
+	}
+
+	return total, nil
+}
+
+func (b *Bot) archive(rows []models.ArchivedInteraction) (_ string, err error) {
+	dir := b.conf.Retention.ArchiveDir
+	if dir == "" {
+		return "", fmt.Errorf("no archive directory configured")
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", errutil.With(err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "interactions-*.jsonl.gz.tmp")
+	if err != nil {
+		return "", errutil.With(err)
+	}
+	defer func() {
+		tmp.Close()
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	zw := gzip.NewWriter(tmp)
+	enc := json.NewEncoder(zw)
+	for _, r := range rows {
+		if err := enc.Encode(r); err != nil {
+			return "", errutil.With(err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return "", errutil.With(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return "", errutil.With(err)
+	}
+
+	return tmp.Name(), nil
+}
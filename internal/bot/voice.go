@@ -0,0 +1,212 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"runtime"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/glotchimo/recast/internal/models"
+	"github.com/graxinc/errutil"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// voice runs voice updates on their own queue so their transactions can't
+// hold up interactions.
+func (b *Bot) voice(guildID string, ctx *GuildContext) {
+	defer b.voices.Done()
+	ctx.Goroutines.Add(1)
+	defer ctx.Goroutines.Add(-1)
+
+	for {
+		select {
+		case <-ctx.Context.Done():
+			return
+		case e := <-ctx.Voice:
+			b.voiceUpdate(guildID, e)
+		}
+	}
+}
+
+func (b *Bot) voiceUpdate(guildID string, e GuildEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			stack := make([]byte, 4096)
+			stack = stack[:runtime.Stack(stack, false)]
+			b.l.Error("panic recovered", "guild", guildID, "event", e.Type, "recovered", r, "stack", stack)
+		}
+
+		trace.SpanFromContext(e.ctx).End()
+		if e.done != nil {
+			e.done()
+		}
+	}()
+
+	b.trackVoice(e.ctx, guildID, e.VoiceUpdate.VoiceState)
+}
+
+func (b *Bot) trackVoice(ctx context.Context, guildID string, v *dg.VoiceState) {
+	if v.UserID == b.s.State().User.ID {
+		return
+	}
+
+	open, err := b.d.OpenVoiceSession(ctx, guildID, v.UserID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			b.l.Error("error fetching open voice session", "error", err, "guild", guildID, "user", v.UserID)
+			return
+		}
+		open = nil
+	}
+
+	if err := b.applyVoice(ctx, guildID, open, v); err != nil {
+		b.l.Error("error tracking voice state", "error", err, "guild", guildID, "user", v.UserID, "channel", v.ChannelID)
+	}
+}
+
+func (b *Bot) applyVoice(ctx context.Context, guildID string, open *models.VoiceSession, v *dg.VoiceState) error {
+	if open == nil && v.ChannelID == "" {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	muted := v.Mute || v.SelfMute
+	deafened := v.Deaf || v.SelfDeaf
+
+	var events []string
+	switch {
+	case open == nil:
+		events = append(events, models.VoiceJoin)
+	case v.ChannelID == "":
+		events = append(events, models.VoiceLeave)
+	case v.ChannelID != open.ChannelID:
+		events = append(events, models.VoiceMove)
+	default:
+		if muted != open.Muted {
+			events = append(events, voiceToggle(muted, models.VoiceMute, models.VoiceUnmute))
+		}
+		if deafened != open.Deafened {
+			events = append(events, voiceToggle(deafened, models.VoiceDeafen, models.VoiceUndeafen))
+		}
+	}
+	if len(events) == 0 {
+		return nil
+	}
+
+	tx, err := b.d.BeginTx(ctx)
+	if err != nil {
+		return errutil.With(err)
+	}
+	defer tx.Rollback()
+
+	channelID := v.ChannelID
+	if channelID == "" {
+		channelID = open.ChannelID
+	}
+	for _, kind := range events {
+		if err := tx.Create(ctx, models.VoiceEvent{GuildID: guildID, UserID: v.UserID, ChannelID: channelID, Type: kind, Created: now}); err != nil {
+			return errutil.With(err)
+		}
+	}
+
+	openWhere := sq.Eq{"guild_id": guildID, "user_id": v.UserID, "ended": nil}
+	if open != nil && open.ChannelID == v.ChannelID {
+		if err := tx.Update(ctx, models.TableVoiceSessions, openWhere, map[string]any{"muted": muted, "deafened": deafened}); err != nil {
+			return errutil.With(err)
+		}
+	} else if open != nil {
+		if err := tx.Update(ctx, models.TableVoiceSessions, openWhere, map[string]any{
+			"ended":    now,
+			"duration": int(open.Duration(now).Seconds()),
+		}); err != nil {
+			return errutil.With(err)
+		}
+	}
+
+	if v.ChannelID != "" && (open == nil || open.ChannelID != v.ChannelID) {
+		session := models.VoiceSession{GuildID: guildID, UserID: v.UserID, ChannelID: v.ChannelID, Muted: muted, Deafened: deafened}
+		if err := tx.Create(ctx, session); err != nil {
+			return errutil.With(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errutil.With(err)
+	}
+
+	return nil
+}
+
+func voiceToggle(on bool, enabled, disabled string) string {
+	if on {
+		return enabled
+	}
+	return disabled
+}
+
+// reconcileVoice closes sessions left open while the bot was offline at the
+// last voice event it saw, since it can't know when those users left, and
+// queues the guild's current voice states to open new ones.
+func (b *Bot) reconcileVoice(g *dg.Guild) {
+	last, err := b.d.LastVoiceEvent(b.ctx, g.ID)
+	if err != nil {
+		b.l.Error("error fetching last voice event", "error", err, "guild", g.ID)
+		return
+	}
+
+	if err := b.closeVoice(b.ctx, g.ID, last); err != nil {
+		b.l.Error("error closing stale voice sessions", "error", err, "guild", g.ID)
+		return
+	}
+
+	for _, v := range g.VoiceStates {
+		b.enqueue(g.ID, GuildEvent{Type: EventTypeVoiceUpdate, VoiceUpdate: &dg.VoiceStateUpdate{VoiceState: v}})
+	}
+}
+
+// closeVoice ends the guild's open sessions at the given time, or when they
+// started if that's later.
+func (b *Bot) closeVoice(ctx context.Context, guildID string, at time.Time) error {
+	open, err := b.d.OpenVoiceSessions(ctx, guildID)
+	if err != nil {
+		return errutil.With(err)
+	}
+	if len(open) == 0 {
+		return nil
+	}
+
+	tx, err := b.d.BeginTx(ctx)
+	if err != nil {
+		return errutil.With(err)
+	}
+	defer tx.Rollback()
+
+	events := make([]models.Mappable, 0, len(open))
+	for _, s := range open {
+		ended := at
+		if ended.Before(s.Created) {
+			ended = s.Created
+		}
+
+		events = append(events, models.VoiceEvent{GuildID: guildID, UserID: s.UserID, ChannelID: s.ChannelID, Type: models.VoiceLeave, Created: ended})
+		if err := tx.Update(ctx, models.TableVoiceSessions, sq.Eq{"guild_id": guildID, "user_id": s.UserID, "ended": nil}, map[string]any{
+			"ended":    ended,
+			"duration": int(s.Duration(ended).Seconds()),
+		}); err != nil {
+			return errutil.With(err)
+		}
+	}
+
+	if err := tx.CreateMany(ctx, events); err != nil {
+		return errutil.With(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errutil.With(err)
+	}
+
+	return nil
+}
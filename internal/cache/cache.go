@@ -8,8 +8,11 @@ import (
 
 	dg "github.com/bwmarrin/discordgo"
 	"github.com/glotchimo/recast/internal/database"
+	"github.com/glotchimo/recast/internal/metrics"
+	"github.com/glotchimo/recast/internal/tracing"
 	"github.com/graxinc/errutil"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
@@ -35,59 +38,95 @@ func NewCache(url string, s *dg.Session, l *slog.Logger, d *database.Database) (
 	}
 	c := redis.NewClient(opt)
 
+	cb := NewCircuitBreaker(cbThreshold, cbResetTimeout)
+	cb.OnStateChange(func(from, to CircuitState) {
+		metrics.BreakerState.Set(float64(to))
+		metrics.BreakerTransitions.WithLabelValues(from.String(), to.String()).Inc()
+	})
+
 	return &Cache{
 		s:        s,
 		c:        c,
 		l:        l,
 		d:        d,
-		cb:       NewCircuitBreaker(cbThreshold, cbResetTimeout),
+		cb:       cb,
 		fallback: NewFallbackCache(fallbackMaxSize),
 	}, nil
 }
 
+func NewLocal(l *slog.Logger) *Cache {
+	return &Cache{
+		l:        l,
+		cb:       NewCircuitBreaker(cbThreshold, cbResetTimeout),
+		fallback: NewFallbackCache(fallbackMaxSize),
+	}
+}
+
 func (c *Cache) Close() error {
+	if c.c == nil {
+		return nil
+	}
 	return c.c.Close()
 }
 
+func (c *Cache) remote() bool {
+	return c.c != nil && c.cb.Allow()
+}
+
 func (c *Cache) Get(ctx context.Context, key string) ([]byte, error) {
-	if !c.cb.Allow() {
+	ctx, span := tracing.Start(ctx, "cache.get", attribute.String("cache.key", key))
+	defer span.End()
+
+	if !c.remote() {
 		if data, ok := c.fallback.Get(key); ok {
+			metrics.CacheRequests.WithLabelValues("get", "fallback_hit").Inc()
 			return data, nil
 		}
+		metrics.CacheRequests.WithLabelValues("get", "fallback_miss").Inc()
 		return nil, fmt.Errorf("circuit breaker open and key not in fallback")
 	}
 
 	data, err := c.c.Get(ctx, key).Bytes()
 	if err != nil {
 		if err != redis.Nil {
+			metrics.CacheRequests.WithLabelValues("get", "error").Inc()
 			c.cb.RecordFailure()
 			if c.cb.IsOpen() {
 				c.l.Warn("redis circuit breaker opened")
 			}
+		} else {
+			metrics.CacheRequests.WithLabelValues("get", "miss").Inc()
 		}
 		if data, ok := c.fallback.Get(key); ok {
+			metrics.CacheRequests.WithLabelValues("get", "fallback_hit").Inc()
 			return data, nil
 		}
 		return nil, err
 	}
 
+	metrics.CacheRequests.WithLabelValues("get", "hit").Inc()
 	c.cb.RecordSuccess()
 	c.fallback.Set(key, data, defaultExpiration)
 	return data, nil
 }
 
 func (c *Cache) Set(ctx context.Context, key string, data []byte, expiration time.Duration) error {
+	ctx, span := tracing.Start(ctx, "cache.set", attribute.String("cache.key", key))
+	defer span.End()
+
 	if expiration == 0 {
 		expiration = defaultExpiration
 	}
 
 	c.fallback.Set(key, data, expiration)
 
-	if !c.cb.Allow() {
+	if !c.remote() {
+		metrics.CacheRequests.WithLabelValues("set", "fallback").Inc()
 		return nil
 	}
 
 	if err := c.c.Set(ctx, key, data, expiration).Err(); err != nil {
+		metrics.CacheRequests.WithLabelValues("set", "error").Inc()
 		c.cb.RecordFailure()
 		if c.cb.IsOpen() {
 			c.l.Warn("redis circuit breaker opened")
@@ -95,22 +134,136 @@ func (c *Cache) Set(ctx context.Context, key string, data []byte, expiration tim
 		return errutil.With(err)
 	}
 
+	metrics.CacheRequests.WithLabelValues("set", "ok").Inc()
 	c.cb.RecordSuccess()
 	return nil
 }
 
+func (c *Cache) Incr(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	ctx, span := tracing.Start(ctx, "cache.incr", attribute.String("cache.key", key))
+	defer span.End()
+
+	if !c.remote() {
+		metrics.CacheRequests.WithLabelValues("incr", "fallback").Inc()
+		count, ttl := c.fallback.Incr(key, window)
+		return count, ttl, nil
+	}
+
+	pipe := c.c.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pttl := pipe.PTTL(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		metrics.CacheRequests.WithLabelValues("incr", "error").Inc()
+		c.cb.RecordFailure()
+		if c.cb.IsOpen() {
+			c.l.Warn("redis circuit breaker opened")
+		}
+		count, ttl := c.fallback.Incr(key, window)
+		return count, ttl, nil
+	}
+
+	count, ttl := incr.Val(), pttl.Val()
+	if ttl < 0 {
+		if err := c.c.PExpire(ctx, key, window).Err(); err != nil {
+			c.cb.RecordFailure()
+			return count, window, errutil.With(err)
+		}
+		ttl = window
+	}
+
+	metrics.CacheRequests.WithLabelValues("incr", "ok").Inc()
+	c.cb.RecordSuccess()
+	return count, ttl, nil
+}
+
 func (c *Cache) Delete(ctx context.Context, key string) error {
+	ctx, span := tracing.Start(ctx, "cache.delete", attribute.String("cache.key", key))
+	defer span.End()
+
 	c.fallback.Delete(key)
 
-	if !c.cb.Allow() {
+	if !c.remote() {
+		metrics.CacheRequests.WithLabelValues("delete", "fallback").Inc()
 		return nil
 	}
 
 	if err := c.c.Del(ctx, key).Err(); err != nil {
+		metrics.CacheRequests.WithLabelValues("delete", "error").Inc()
+		c.cb.RecordFailure()
+		return errutil.With(err)
+	}
+
+	metrics.CacheRequests.WithLabelValues("delete", "ok").Inc()
+	c.cb.RecordSuccess()
+	return nil
+}
+
+var push = redis.NewScript(`
+redis.call('SET', KEYS[2], ARGV[1], 'PX', ARGV[3])
+redis.call('ZADD', KEYS[1], 'NX', ARGV[4], KEYS[2])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+local over = redis.call('ZCARD', KEYS[1]) - tonumber(ARGV[2])
+if over > 0 then
+	local evicted = redis.call('ZRANGE', KEYS[1], 0, over - 1)
+	redis.call('ZREMRANGEBYRANK', KEYS[1], 0, over - 1)
+	redis.call('DEL', unpack(evicted))
+end
+return over
+`)
+
+// Push sets key and tracks it in index, evicting the oldest keys in index
+// once it holds more than limit.
+func (c *Cache) Push(ctx context.Context, index, key string, data []byte, limit int, expiration time.Duration) error {
+	ctx, span := tracing.Start(ctx, "cache.push", attribute.String("cache.key", key), attribute.String("cache.index", index))
+	defer span.End()
+
+	if expiration == 0 {
+		expiration = defaultExpiration
+	}
+
+	c.fallback.Push(index, key, data, limit, expiration)
+
+	if !c.remote() {
+		metrics.CacheRequests.WithLabelValues("push", "fallback").Inc()
+		return nil
+	}
+
+	args := []any{data, limit, expiration.Milliseconds(), time.Now().UnixMilli()}
+	if err := push.Run(ctx, c.c, []string{index, key}, args...).Err(); err != nil {
+		metrics.CacheRequests.WithLabelValues("push", "error").Inc()
+		c.cb.RecordFailure()
+		if c.cb.IsOpen() {
+			c.l.Warn("redis circuit breaker opened")
+		}
+		return errutil.With(err)
+	}
+
+	metrics.CacheRequests.WithLabelValues("push", "ok").Inc()
+	c.cb.RecordSuccess()
+	return nil
+}
+
+func (c *Cache) Remove(ctx context.Context, index, key string) error {
+	ctx, span := tracing.Start(ctx, "cache.remove", attribute.String("cache.key", key), attribute.String("cache.index", index))
+	defer span.End()
+
+	c.fallback.Remove(index, key)
+
+	if !c.remote() {
+		metrics.CacheRequests.WithLabelValues("remove", "fallback").Inc()
+		return nil
+	}
+
+	pipe := c.c.TxPipeline()
+	pipe.Del(ctx, key)
+	pipe.ZRem(ctx, index, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		metrics.CacheRequests.WithLabelValues("remove", "error").Inc()
 		c.cb.RecordFailure()
 		return errutil.With(err)
 	}
 
+	metrics.CacheRequests.WithLabelValues("remove", "ok").Inc()
 	c.cb.RecordSuccess()
 	return nil
 }
@@ -118,3 +271,7 @@ func (c *Cache) Delete(ctx context.Context, key string) error {
 func (c *Cache) Client() *redis.Client {
 	return c.c
 }
+
+func (c *Cache) Breaker() *CircuitBreaker {
+	return c.cb
+}
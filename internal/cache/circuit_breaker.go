@@ -13,6 +13,18 @@ const (
 	StateHalfOpen
 )
 
+func (s CircuitState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
 type CircuitBreaker struct {
 	mu              sync.RWMutex
 	state           CircuitState
@@ -23,6 +35,7 @@ type CircuitBreaker struct {
 	resetTimeout    time.Duration
 	halfOpenMax     int
 	lastStateChange time.Time
+	onStateChange   func(from, to CircuitState)
 }
 
 func NewCircuitBreaker(threshold int, resetTimeout time.Duration) *CircuitBreaker {
@@ -49,9 +62,8 @@ func (cb *CircuitBreaker) Allow() bool {
 		return true
 	case StateOpen:
 		if time.Since(cb.lastFailure) > cb.resetTimeout {
-			cb.state = StateHalfOpen
+			cb.transition(StateHalfOpen)
 			cb.successes = 0
-			cb.lastStateChange = time.Now()
 			return true
 		}
 		return false
@@ -72,9 +84,8 @@ func (cb *CircuitBreaker) RecordSuccess() {
 	case StateHalfOpen:
 		cb.successes++
 		if cb.successes >= cb.halfOpenMax {
-			cb.state = StateClosed
+			cb.transition(StateClosed)
 			cb.failures = 0
-			cb.lastStateChange = time.Now()
 		}
 	}
 }
@@ -89,12 +100,10 @@ func (cb *CircuitBreaker) RecordFailure() {
 	case StateClosed:
 		cb.failures++
 		if cb.failures >= cb.threshold {
-			cb.state = StateOpen
-			cb.lastStateChange = time.Now()
+			cb.transition(StateOpen)
 		}
 	case StateHalfOpen:
-		cb.state = StateOpen
-		cb.lastStateChange = time.Now()
+		cb.transition(StateOpen)
 	}
 }
 
@@ -102,10 +111,25 @@ func (cb *CircuitBreaker) Reset() {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
-	cb.state = StateClosed
+	cb.transition(StateClosed)
 	cb.failures = 0
 	cb.successes = 0
+}
+
+func (cb *CircuitBreaker) OnStateChange(fn func(from, to CircuitState)) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.onStateChange = fn
+}
+
+func (cb *CircuitBreaker) transition(to CircuitState) {
+	from := cb.state
+	cb.state = to
 	cb.lastStateChange = time.Now()
+
+	if cb.onStateChange != nil && from != to {
+		cb.onStateChange(from, to)
+	}
 }
 
 func (cb *CircuitBreaker) IsOpen() bool {
@@ -1,6 +1,8 @@
 package cache
 
 import (
+	"slices"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -13,12 +15,14 @@ type fallbackEntry struct {
 type FallbackCache struct {
 	mu      sync.RWMutex
 	entries map[string]fallbackEntry
+	indexes map[string][]string
 	maxSize int
 }
 
 func NewFallbackCache(maxSize int) *FallbackCache {
 	fc := &FallbackCache{
 		entries: make(map[string]fallbackEntry),
+		indexes: make(map[string][]string),
 		maxSize: maxSize,
 	}
 	go fc.cleanup()
@@ -55,12 +59,68 @@ func (fc *FallbackCache) Set(key string, data []byte, ttl time.Duration) {
 	}
 }
 
+func (fc *FallbackCache) Incr(key string, ttl time.Duration) (int64, time.Duration) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	now := time.Now()
+	entry, ok := fc.entries[key]
+	if !ok || now.After(entry.expiresAt) {
+		if len(fc.entries) >= fc.maxSize {
+			fc.evictOldest()
+		}
+		entry = fallbackEntry{expiresAt: now.Add(ttl)}
+	}
+
+	count, _ := strconv.ParseInt(string(entry.data), 10, 64)
+	count++
+	entry.data = []byte(strconv.FormatInt(count, 10))
+	fc.entries[key] = entry
+
+	return count, entry.expiresAt.Sub(now)
+}
+
 func (fc *FallbackCache) Delete(key string) {
 	fc.mu.Lock()
 	defer fc.mu.Unlock()
 	delete(fc.entries, key)
 }
 
+func (fc *FallbackCache) Push(index, key string, data []byte, limit int, ttl time.Duration) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	if _, ok := fc.entries[key]; !ok {
+		if len(fc.entries) >= fc.maxSize {
+			fc.evictOldest()
+		}
+		fc.indexes[index] = append(fc.indexes[index], key)
+	}
+
+	fc.entries[key] = fallbackEntry{
+		data:      data,
+		expiresAt: time.Now().Add(ttl),
+	}
+
+	if over := len(fc.indexes[index]) - limit; over > 0 {
+		for _, evicted := range fc.indexes[index][:over] {
+			delete(fc.entries, evicted)
+		}
+		fc.indexes[index] = slices.Clone(fc.indexes[index][over:])
+	}
+}
+
+func (fc *FallbackCache) Remove(index, key string) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	delete(fc.entries, key)
+	fc.indexes[index] = slices.DeleteFunc(fc.indexes[index], func(k string) bool { return k == key })
+	if len(fc.indexes[index]) == 0 {
+		delete(fc.indexes, index)
+	}
+}
+
 func (fc *FallbackCache) evictOldest() {
 	var oldestKey string
 	var oldestTime time.Time
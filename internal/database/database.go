@@ -4,15 +4,23 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"log/slog"
+	"maps"
+	"slices"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
+	"github.com/glotchimo/recast/internal/metrics"
 	"github.com/glotchimo/recast/internal/models"
+	"github.com/glotchimo/recast/internal/tracing"
+	"github.com/glotchimo/recast/migrations"
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 	"github.com/graxinc/errutil"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Database struct {
@@ -35,10 +43,6 @@ func NewDatabase(l *slog.Logger, databaseURL string) (*Database, error) {
 	cache := sq.NewStmtCache(db)
 	database := Database{l: l, db: db, builder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar).RunWith(cache)}
 
-	if err := database.Migrate(databaseURL); err != nil {
-		return nil, errutil.With(err)
-	}
-
 	return &database, nil
 }
 
@@ -46,11 +50,38 @@ func (db *Database) Close() error {
 	return db.db.Close()
 }
 
-func (db *Database) Migrate(databaseURL string) error {
-	m, err := migrate.New("file://migrations", databaseURL)
+func (db *Database) Ping(ctx context.Context) error {
+	return db.db.PingContext(ctx)
+}
+
+func NewMigrate(databaseURL, migrationsPath string) (*migrate.Migrate, error) {
+	if migrationsPath != "" {
+		m, err := migrate.New("file://"+migrationsPath, databaseURL)
+		if err != nil {
+			return nil, errutil.With(err)
+		}
+		return m, nil
+	}
+
+	source, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return nil, errutil.With(err)
+	}
+
+	m, err := migrate.NewWithSourceInstance("iofs", source, databaseURL)
+	if err != nil {
+		return nil, errutil.With(err)
+	}
+
+	return m, nil
+}
+
+func (db *Database) Migrate(databaseURL, migrationsPath string) error {
+	m, err := NewMigrate(databaseURL, migrationsPath)
 	if err != nil {
 		return errutil.With(err)
 	}
+	defer m.Close()
 
 	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
 		return errutil.With(err)
@@ -67,6 +98,10 @@ func (db *Database) Migrate(databaseURL string) error {
 }
 
 func (db *Database) Create(ctx context.Context, m models.Mappable) error {
+	defer metrics.ObserveQuery("create", string(m.Table()))()
+	ctx, span := tracing.Start(ctx, "db.create", attribute.String("db.table", string(m.Table())))
+	defer span.End()
+
 	data := m.Map()
 	data["created"] = time.Now().UTC()
 	q := db.builder.
@@ -80,7 +115,33 @@ func (db *Database) Create(ctx context.Context, m models.Mappable) error {
 	return nil
 }
 
+func (db *Database) CreateMany(ctx context.Context, ms []models.Mappable) error {
+	if len(ms) == 0 {
+		return nil
+	}
+
+	table := string(ms[0].Table())
+	defer metrics.ObserveQuery("create_many", table)()
+	ctx, span := tracing.Start(ctx, "db.create_many", attribute.String("db.table", table), attribute.Int("db.rows", len(ms)))
+	defer span.End()
+
+	q, err := insertMany(db.builder, ms)
+	if err != nil {
+		return errutil.With(err)
+	}
+
+	if _, err := q.ExecContext(ctx); err != nil {
+		return errutil.With(err)
+	}
+
+	return nil
+}
+
 func (db *Database) Update(ctx context.Context, table models.Table, where sq.Eq, updates map[string]any) error {
+	defer metrics.ObserveQuery("update", string(table))()
+	ctx, span := tracing.Start(ctx, "db.update", attribute.String("db.table", string(table)))
+	defer span.End()
+
 	updates["updated"] = time.Now().UTC()
 	q := db.builder.
 		Update(string(table)).
@@ -94,6 +155,10 @@ func (db *Database) Update(ctx context.Context, table models.Table, where sq.Eq,
 }
 
 func (db *Database) Delete(ctx context.Context, table models.Table, where sq.Eq) error {
+	defer metrics.ObserveQuery("delete", string(table))()
+	ctx, span := tracing.Start(ctx, "db.delete", attribute.String("db.table", string(table)))
+	defer span.End()
+
 	var hasDeletedColumn bool
 	err := db.builder.
 		Select("1").
@@ -137,6 +202,10 @@ func (db *Database) Delete(ctx context.Context, table models.Table, where sq.Eq)
 }
 
 func (db *Database) Count(ctx context.Context, table models.Table, where sq.Eq) (int, error) {
+	defer metrics.ObserveQuery("count", string(table))()
+	ctx, span := tracing.Start(ctx, "db.count", attribute.String("db.table", string(table)))
+	defer span.End()
+
 	var count int
 
 	q := db.builder.
@@ -151,34 +220,38 @@ func (db *Database) Count(ctx context.Context, table models.Table, where sq.Eq)
 	return count, nil
 }
 
-type Tx struct {
+type sqlTx struct {
 	tx      *sql.Tx
 	builder sq.StatementBuilderType
 	l       *slog.Logger
 }
 
-func (db *Database) BeginTx(ctx context.Context) (*Tx, error) {
+func (db *Database) BeginTx(ctx context.Context) (Tx, error) {
 	tx, err := db.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, errutil.With(err)
 	}
 
-	return &Tx{
+	return &sqlTx{
 		tx:      tx,
 		builder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar).RunWith(tx),
 		l:       db.l,
 	}, nil
 }
 
-func (tx *Tx) Commit() error {
+func (tx *sqlTx) Commit() error {
 	return tx.tx.Commit()
 }
 
-func (tx *Tx) Rollback() error {
+func (tx *sqlTx) Rollback() error {
 	return tx.tx.Rollback()
 }
 
-func (tx *Tx) Create(ctx context.Context, m models.Mappable) error {
+func (tx *sqlTx) Create(ctx context.Context, m models.Mappable) error {
+	defer metrics.ObserveQuery("create", string(m.Table()))()
+	ctx, span := tracing.Start(ctx, "db.create", attribute.String("db.table", string(m.Table())))
+	defer span.End()
+
 	data := m.Map()
 	data["created"] = time.Now().UTC()
 
@@ -193,7 +266,33 @@ func (tx *Tx) Create(ctx context.Context, m models.Mappable) error {
 	return nil
 }
 
-func (tx *Tx) Update(ctx context.Context, table models.Table, where sq.Eq, updates map[string]any) error {
+func (tx *sqlTx) CreateMany(ctx context.Context, ms []models.Mappable) error {
+	if len(ms) == 0 {
+		return nil
+	}
+
+	table := string(ms[0].Table())
+	defer metrics.ObserveQuery("create_many", table)()
+	ctx, span := tracing.Start(ctx, "db.create_many", attribute.String("db.table", table), attribute.Int("db.rows", len(ms)))
+	defer span.End()
+
+	q, err := insertMany(tx.builder, ms)
+	if err != nil {
+		return errutil.With(err)
+	}
+
+	if _, err := q.ExecContext(ctx); err != nil {
+		return errutil.With(err)
+	}
+
+	return nil
+}
+
+func (tx *sqlTx) Update(ctx context.Context, table models.Table, where sq.Eq, updates map[string]any) error {
+	defer metrics.ObserveQuery("update", string(table))()
+	ctx, span := tracing.Start(ctx, "db.update", attribute.String("db.table", string(table)))
+	defer span.End()
+
 	updates["updated"] = time.Now().UTC()
 
 	q := tx.builder.
@@ -208,7 +307,11 @@ func (tx *Tx) Update(ctx context.Context, table models.Table, where sq.Eq, updat
 	return nil
 }
 
-func (tx *Tx) Delete(ctx context.Context, table models.Table, where sq.Eq) error {
+func (tx *sqlTx) Delete(ctx context.Context, table models.Table, where sq.Eq) error {
+	defer metrics.ObserveQuery("delete", string(table))()
+	ctx, span := tracing.Start(ctx, "db.delete", attribute.String("db.table", string(table)))
+	defer span.End()
+
 	var hasDeletedColumn bool
 	err := tx.builder.
 		Select("1").
@@ -251,7 +354,11 @@ func (tx *Tx) Delete(ctx context.Context, table models.Table, where sq.Eq) error
 	return nil
 }
 
-func (tx *Tx) Count(ctx context.Context, table models.Table, where sq.Eq) (int, error) {
+func (tx *sqlTx) Count(ctx context.Context, table models.Table, where sq.Eq) (int, error) {
+	defer metrics.ObserveQuery("count", string(table))()
+	ctx, span := tracing.Start(ctx, "db.count", attribute.String("db.table", string(table)))
+	defer span.End()
+
 	var count int
 
 	q := tx.builder.
@@ -267,6 +374,10 @@ func (tx *Tx) Count(ctx context.Context, table models.Table, where sq.Eq) (int,
 }
 
 func (db *Database) PutGuild(ctx context.Context, guild models.Guild) error {
+	defer metrics.ObserveQuery("put", string(models.TableGuilds))()
+	ctx, span := tracing.Start(ctx, "db.put", attribute.String("db.table", string(models.TableGuilds)))
+	defer span.End()
+
 	m := guild.Map()
 	m["created"] = time.Now()
 	q := db.builder.
@@ -281,6 +392,10 @@ func (db *Database) PutGuild(ctx context.Context, guild models.Guild) error {
 }
 
 func (db *Database) GetGuild(ctx context.Context, id string) (*models.Guild, error) {
+	defer metrics.ObserveQuery("get", string(models.TableGuilds))()
+	ctx, span := tracing.Start(ctx, "db.get", attribute.String("db.table", string(models.TableGuilds)))
+	defer span.End()
+
 	var g models.Guild
 	var settingsRaw []byte
 
@@ -303,7 +418,7 @@ func (db *Database) GetGuild(ctx context.Context, id string) (*models.Guild, err
 		&g.Updated,
 		&g.Deleted,
 	); err != nil {
-		return nil, errutil.With(err)
+		return nil, errutil.Wrap(err, sql.ErrNoRows)
 	}
 
 	if err := json.Unmarshal(settingsRaw, &g.Settings); err != nil {
@@ -312,3 +427,154 @@ func (db *Database) GetGuild(ctx context.Context, id string) (*models.Guild, err
 
 	return &g, nil
 }
+
+func (db *Database) PutBot(ctx context.Context, bot models.Bot) error {
+	defer metrics.ObserveQuery("put", string(models.TableBot))()
+	ctx, span := tracing.Start(ctx, "db.put", attribute.String("db.table", string(models.TableBot)))
+	defer span.End()
+
+	m := bot.Map()
+	m["created"] = time.Now()
+	q := db.builder.
+		Insert(string(models.TableBot)).
+		SetMap(m).
+		Suffix(`ON CONFLICT (id) DO UPDATE SET settings = EXCLUDED.settings, updated = CURRENT_TIMESTAMP`)
+	if _, err := q.ExecContext(ctx); err != nil {
+		return errutil.With(err)
+	}
+
+	return nil
+}
+
+func (db *Database) GetBot(ctx context.Context, id string) (*models.Bot, error) {
+	defer metrics.ObserveQuery("get", string(models.TableBot))()
+	ctx, span := tracing.Start(ctx, "db.get", attribute.String("db.table", string(models.TableBot)))
+	defer span.End()
+
+	var b models.Bot
+	var settingsRaw []byte
+
+	q := db.builder.
+		Select(
+			"id",
+			"settings",
+			"created",
+			"updated").
+		From(string(models.TableBot)).
+		Where(sq.Eq{"id": id})
+
+	if err := q.QueryRowContext(ctx).Scan(
+		&b.ID,
+		&settingsRaw,
+		&b.Created,
+		&b.Updated,
+	); err != nil {
+		return nil, errutil.Wrap(err, sql.ErrNoRows)
+	}
+
+	if err := json.Unmarshal(settingsRaw, &b.Settings); err != nil {
+		return nil, errutil.With(err)
+	}
+
+	return &b, nil
+}
+
+func (db *Database) PurgeInteractions(ctx context.Context, now time.Time, defaultDays, limit int, archive func([]models.ArchivedInteraction) error) (int, error) {
+	defer metrics.ObserveQuery("purge", string(models.TableInteractions))()
+	ctx, span := tracing.Start(ctx, "db.purge", attribute.String("db.table", string(models.TableInteractions)))
+	defer span.End()
+
+	days := sq.Expr("COALESCE((g.settings->>'retention_days')::int, ?)", defaultDays)
+	expired := sq.
+		Select("i.ctid").
+		From(string(models.TableInteractions) + " i").
+		LeftJoin(string(models.TableGuilds) + " g ON g.id = i.guild_id").
+		Where(sq.Expr("? > 0", days)).
+		Where(sq.Expr("i.created < ?::timestamp - make_interval(days => ?)", now, days)).
+		Limit(uint64(limit))
+
+	query, args, err := db.builder.
+		Delete(string(models.TableInteractions)).
+		Where(sq.Expr("ctid IN (?)", expired)).
+		Suffix("RETURNING COALESCE(id, ''), COALESCE(guild_id, ''), COALESCE(user_id, ''), COALESCE(command, ''), created, interaction").
+		ToSql()
+	if err != nil {
+		return 0, errutil.With(err)
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, errutil.With(err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, errutil.With(err)
+	}
+	defer rows.Close()
+
+	var archived []models.ArchivedInteraction
+	for rows.Next() {
+		var a models.ArchivedInteraction
+		if err := rows.Scan(&a.ID, &a.GuildID, &a.UserID, &a.Command, &a.Created, &a.Interaction); err != nil {
+			return 0, errutil.With(err)
+		}
+		archived = append(archived, a)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, errutil.With(err)
+	}
+
+	if len(archived) == 0 {
+		return 0, nil
+	}
+
+	if err := archive(archived); err != nil {
+		return 0, errutil.With(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, errutil.With(err)
+	}
+
+	return len(archived), nil
+}
+
+func insertMany(builder sq.StatementBuilderType, ms []models.Mappable) (sq.InsertBuilder, error) {
+	now := time.Now().UTC()
+	table := ms[0].Table()
+	q := builder.Insert(string(table))
+
+	var columns []string
+	for i, m := range ms {
+		if m.Table() != table {
+			return q, fmt.Errorf("batch mixes rows for %s and %s", table, m.Table())
+		}
+
+		data := m.Map()
+		if created, ok := data["created"].(time.Time); !ok || created.IsZero() {
+			data["created"] = now
+		}
+
+		if i == 0 {
+			columns = slices.Sorted(maps.Keys(data))
+			q = q.Columns(columns...)
+		}
+		if len(data) != len(columns) {
+			return q, fmt.Errorf("batch rows for %s have different columns", table)
+		}
+
+		values := make([]any, len(columns))
+		for j, column := range columns {
+			v, ok := data[column]
+			if !ok {
+				return q, fmt.Errorf("batch rows for %s have different columns", table)
+			}
+			values[j] = v
+		}
+		q = q.Values(values...)
+	}
+
+	return q, nil
+}
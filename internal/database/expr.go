@@ -0,0 +1,77 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type JSONSet struct {
+	Column string
+	Path   []string
+	Value  any
+}
+
+func SetJSON(column string, value any, path ...string) JSONSet {
+	return JSONSet{Column: column, Path: path, Value: value}
+}
+
+func (j JSONSet) ToSql() (string, []any, error) {
+	raw, err := json.Marshal(j.Value)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// jsonb_set only creates the last key of a path, so missing parents are
+	// created first.
+	expr := fmt.Sprintf("COALESCE(%s, '{}'::jsonb)", j.Column)
+	var args []any
+	for i := 1; i < len(j.Path); i++ {
+		parent := textArray(j.Path[:i])
+		expr = fmt.Sprintf("jsonb_set(%s, ?::text[], COALESCE(%s #> ?::text[], '{}'::jsonb))", expr, j.Column)
+		args = append(args, parent, parent)
+	}
+
+	sql := fmt.Sprintf("jsonb_set(%s, ?::text[], ?::jsonb)", expr)
+	return sql, append(args, textArray(j.Path), string(raw)), nil
+}
+
+func textArray(elems []string) string {
+	quoted := make([]string, len(elems))
+	for i, e := range elems {
+		e = strings.ReplaceAll(e, `\`, `\\`)
+		quoted[i] = `"` + strings.ReplaceAll(e, `"`, `\"`) + `"`
+	}
+	return "{" + strings.Join(quoted, ",") + "}"
+}
+
+func (j JSONSet) apply(current any) ([]byte, error) {
+	doc := map[string]any{}
+	if raw, ok := text(current); ok && len(raw) > 0 {
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return nil, err
+		}
+	}
+
+	raw, err := json.Marshal(j.Value)
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, err
+	}
+
+	node := doc
+	for _, key := range j.Path[:len(j.Path)-1] {
+		next, ok := node[key].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			node[key] = next
+		}
+		node = next
+	}
+	node[j.Path[len(j.Path)-1]] = value
+
+	return json.Marshal(doc)
+}
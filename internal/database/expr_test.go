@@ -0,0 +1,160 @@
+package database
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestJSONSetToSql(t *testing.T) {
+	tests := []struct {
+		name     string
+		set      JSONSet
+		wantSQL  string
+		wantArgs []any
+	}{
+		{
+			name:     "top level key",
+			set:      SetJSON("settings", "123", "log_channel_id"),
+			wantSQL:  "jsonb_set(COALESCE(settings, '{}'::jsonb), ?::text[], ?::jsonb)",
+			wantArgs: []any{`{"log_channel_id"}`, `"123"`},
+		},
+		{
+			name:    "nested key",
+			set:     SetJSON("settings", map[string]any{"alias": "pong"}, "commands", "ping"),
+			wantSQL: "jsonb_set(jsonb_set(COALESCE(settings, '{}'::jsonb), ?::text[], COALESCE(settings #> ?::text[], '{}'::jsonb)), ?::text[], ?::jsonb)",
+			wantArgs: []any{
+				`{"commands"}`, `{"commands"}`,
+				`{"commands","ping"}`, `{"alias":"pong"}`,
+			},
+		},
+		{
+			name: "deeply nested key",
+			set:  SetJSON("settings", true, "a", "b", "c"),
+			wantSQL: "jsonb_set(jsonb_set(jsonb_set(COALESCE(settings, '{}'::jsonb), ?::text[], COALESCE(settings #> ?::text[], '{}'::jsonb)), " +
+				"?::text[], COALESCE(settings #> ?::text[], '{}'::jsonb)), ?::text[], ?::jsonb)",
+			wantArgs: []any{
+				`{"a"}`, `{"a"}`,
+				`{"a","b"}`, `{"a","b"}`,
+				`{"a","b","c"}`, `true`,
+			},
+		},
+		{
+			name:     "null value",
+			set:      SetJSON("settings", nil, "retention_days"),
+			wantSQL:  "jsonb_set(COALESCE(settings, '{}'::jsonb), ?::text[], ?::jsonb)",
+			wantArgs: []any{`{"retention_days"}`, `null`},
+		},
+		{
+			name:    "quoted path elements",
+			set:     SetJSON("settings", 1, `say "hi"`, `back\slash`, "a,b"),
+			wantSQL: "jsonb_set(jsonb_set(jsonb_set(COALESCE(settings, '{}'::jsonb), ?::text[], COALESCE(settings #> ?::text[], '{}'::jsonb)), ?::text[], COALESCE(settings #> ?::text[], '{}'::jsonb)), ?::text[], ?::jsonb)",
+			wantArgs: []any{
+				`{"say \"hi\""}`, `{"say \"hi\""}`,
+				`{"say \"hi\"","back\\slash"}`, `{"say \"hi\"","back\\slash"}`,
+				`{"say \"hi\"","back\\slash","a,b"}`, `1`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args, err := tt.set.ToSql()
+			if err != nil {
+				t.Fatalf("ToSql() error = %v", err)
+			}
+			if sql != tt.wantSQL {
+				t.Errorf("ToSql() sql = %s, want %s", sql, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("ToSql() args = %q, want %q", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestJSONSetToSqlUnmarshalable(t *testing.T) {
+	if _, _, err := SetJSON("settings", make(chan int), "key").ToSql(); err == nil {
+		t.Error("ToSql() error = nil, want an error for a value that can't be marshaled")
+	}
+}
+
+func TestJSONSetApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		set     JSONSet
+		current any
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "null document",
+			set:     SetJSON("settings", "123", "log_channel_id"),
+			current: nil,
+			want:    `{"log_channel_id":"123"}`,
+		},
+		{
+			name:    "keeps siblings",
+			set:     SetJSON("settings", map[string]any{"alias": "pong"}, "commands", "ping"),
+			current: []byte(`{"log_channel_id":"123","commands":{"stats":{"disabled":true}}}`),
+			want:    `{"commands":{"ping":{"alias":"pong"},"stats":{"disabled":true}},"log_channel_id":"123"}`,
+		},
+		{
+			name:    "replaces existing value",
+			set:     SetJSON("settings", map[string]any{}, "commands", "ping"),
+			current: `{"commands":{"ping":{"alias":"pong"}}}`,
+			want:    `{"commands":{"ping":{}}}`,
+		},
+		{
+			name:    "creates missing parents",
+			set:     SetJSON("settings", 1, "a", "b", "c"),
+			current: []byte(`{}`),
+			want:    `{"a":{"b":{"c":1}}}`,
+		},
+		{
+			name:    "replaces scalar parent",
+			set:     SetJSON("settings", 1, "a", "b"),
+			current: []byte(`{"a":"x"}`),
+			want:    `{"a":{"b":1}}`,
+		},
+		{
+			name:    "null value",
+			set:     SetJSON("settings", nil, "retention_days"),
+			current: []byte(`{"retention_days":30}`),
+			want:    `{"retention_days":null}`,
+		},
+		{
+			name:    "invalid document",
+			set:     SetJSON("settings", 1, "a"),
+			current: []byte(`not json`),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.set.apply(tt.current)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("apply() = %s, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("apply() error = %v", err)
+			}
+
+			// Compare decoded documents so key order doesn't matter.
+			var gotDoc, wantDoc any
+			if err := json.Unmarshal(got, &gotDoc); err != nil {
+				t.Fatalf("apply() returned invalid JSON %s: %v", got, err)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &wantDoc); err != nil {
+				t.Fatalf("invalid want %s: %v", tt.want, err)
+			}
+			if !reflect.DeepEqual(gotDoc, wantDoc) {
+				t.Errorf("apply() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,857 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/glotchimo/recast/internal/models"
+	"github.com/graxinc/errutil"
+)
+
+type row map[string]any
+
+type tables map[models.Table][]row
+
+func (t tables) clone() tables {
+	c := make(tables, len(t))
+	for table, rows := range t {
+		c[table] = make([]row, len(rows))
+		for i, r := range rows {
+			c[table][i] = maps.Clone(r)
+		}
+	}
+	return c
+}
+
+func (t tables) create(m models.Mappable) {
+	r := row(m.Map())
+	now := time.Now().UTC()
+	r["created"] = now
+	if _, ok := r["updated"]; !ok {
+		r["updated"] = now
+	}
+	t[m.Table()] = append(t[m.Table()], r)
+}
+
+func (t tables) createMany(ms []models.Mappable) error {
+	if len(ms) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	table := ms[0].Table()
+	rows := make([]row, 0, len(ms))
+	for _, m := range ms {
+		if m.Table() != table {
+			return fmt.Errorf("batch mixes rows for %s and %s", table, m.Table())
+		}
+
+		r := row(m.Map())
+		if created, ok := r["created"].(time.Time); !ok || created.IsZero() {
+			r["created"] = now
+		}
+		if _, ok := r["updated"]; !ok {
+			r["updated"] = now
+		}
+		rows = append(rows, r)
+	}
+
+	t[table] = append(t[table], rows...)
+	return nil
+}
+
+func (t tables) update(table models.Table, where sq.Eq, updates map[string]any) error {
+	for column, v := range updates {
+		if _, ok := v.(JSONSet); ok {
+			continue
+		}
+		if _, ok := v.(sq.Sqlizer); ok {
+			return fmt.Errorf("memory store: unsupported expression for %s.%s", table, column)
+		}
+	}
+
+	updates["updated"] = time.Now().UTC()
+	for _, r := range t[table] {
+		if !r.matches(where) {
+			continue
+		}
+		for column, v := range updates {
+			if set, ok := v.(JSONSet); ok {
+				raw, err := set.apply(r[set.Column])
+				if err != nil {
+					return errutil.With(err)
+				}
+				r[column] = raw
+				continue
+			}
+			r[column] = v
+		}
+	}
+
+	return nil
+}
+
+// softDeleted mirrors the Postgres check: a table is soft-deleted when its
+// rows carry a deleted column.
+func (t tables) softDeleted(table models.Table) bool {
+	for _, r := range t[table] {
+		if _, ok := r["deleted"]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (t tables) delete(table models.Table, where sq.Eq) {
+	if t.softDeleted(table) {
+		now := time.Now().UTC()
+		for _, r := range t[table] {
+			if r.matches(where) {
+				r["deleted"] = now
+				r["updated"] = now
+			}
+		}
+		return
+	}
+
+	kept := t[table][:0]
+	for _, r := range t[table] {
+		if !r.matches(where) {
+			kept = append(kept, r)
+		}
+	}
+	t[table] = kept
+}
+
+func (t tables) upsert(table models.Table, key sq.Eq, values row) {
+	for _, r := range t[table] {
+		if r.matches(key) {
+			maps.Copy(r, values)
+			return
+		}
+	}
+
+	r := row(maps.Clone(key))
+	maps.Copy(r, values)
+	t[table] = append(t[table], r)
+}
+
+func (t tables) count(table models.Table, where sq.Eq) int {
+	var n int
+	for _, r := range t[table] {
+		if r.matches(where) {
+			n++
+		}
+	}
+	return n
+}
+
+func (r row) matches(where sq.Eq) bool {
+	for column, want := range where {
+		got := r[column]
+
+		rv := reflect.ValueOf(want)
+		if want != nil && rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8 {
+			found := false
+			for i := range rv.Len() {
+				if equal(got, rv.Index(i).Interface()) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+			continue
+		}
+
+		if !equal(got, want) {
+			return false
+		}
+	}
+	return true
+}
+
+func equal(a, b any) bool {
+	if isNull(a) || isNull(b) {
+		return isNull(a) && isNull(b)
+	}
+
+	ab, aok := text(a)
+	bb, bok := text(b)
+	if aok && bok {
+		return bytes.Equal(ab, bb)
+	}
+
+	if at, ok := a.(time.Time); ok {
+		bt, ok := b.(time.Time)
+		return ok && at.Equal(bt)
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func isNull(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Pointer && rv.IsNil()
+}
+
+func text(v any) ([]byte, bool) {
+	switch v := v.(type) {
+	case string:
+		return []byte(v), true
+	case []byte:
+		return v, true
+	}
+	return nil, false
+}
+
+type Memory struct {
+	mu     sync.Mutex
+	tables tables
+}
+
+func NewMemory() *Memory {
+	return &Memory{tables: tables{}}
+}
+
+func (m *Memory) Create(ctx context.Context, mp models.Mappable) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tables.create(mp)
+	return nil
+}
+
+func (m *Memory) CreateMany(ctx context.Context, ms []models.Mappable) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.tables.createMany(ms)
+}
+
+func (m *Memory) Update(ctx context.Context, table models.Table, where sq.Eq, updates map[string]any) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.tables.update(table, where, updates)
+}
+
+func (m *Memory) Delete(ctx context.Context, table models.Table, where sq.Eq) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tables.delete(table, where)
+	return nil
+}
+
+func (m *Memory) Count(ctx context.Context, table models.Table, where sq.Eq) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.tables.count(table, where), nil
+}
+
+func (m *Memory) PutGuild(ctx context.Context, guild models.Guild) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, r := range m.tables[models.TableGuilds] {
+		if equal(r["id"], guild.ID) {
+			r["name"] = guild.Name
+			return nil
+		}
+	}
+
+	m.tables.create(guild)
+	return nil
+}
+
+func (m *Memory) GetGuild(ctx context.Context, id string) (*models.Guild, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, r := range m.tables[models.TableGuilds] {
+		if !equal(r["id"], id) {
+			continue
+		}
+
+		var g models.Guild
+		g.ID, _ = r["id"].(string)
+		g.Name, _ = r["name"].(string)
+		g.Created, _ = r["created"].(time.Time)
+		g.Updated, _ = r["updated"].(time.Time)
+		switch deleted := r["deleted"].(type) {
+		case time.Time:
+			g.Deleted = &deleted
+		case *time.Time:
+			g.Deleted = deleted
+		}
+
+		if settings, ok := text(r["settings"]); ok {
+			if err := json.Unmarshal(settings, &g.Settings); err != nil {
+				return nil, errutil.With(err)
+			}
+		}
+
+		return &g, nil
+	}
+
+	return nil, errutil.Wrap(sql.ErrNoRows, sql.ErrNoRows)
+}
+
+func (m *Memory) PutBot(ctx context.Context, bot models.Bot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, r := range m.tables[models.TableBot] {
+		if equal(r["id"], bot.ID) {
+			maps.Copy(r, bot.Map())
+			r["updated"] = time.Now().UTC()
+			return nil
+		}
+	}
+
+	m.tables.create(bot)
+	return nil
+}
+
+func (m *Memory) GetBot(ctx context.Context, id string) (*models.Bot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, r := range m.tables[models.TableBot] {
+		if !equal(r["id"], id) {
+			continue
+		}
+
+		var b models.Bot
+		b.ID, _ = r["id"].(string)
+		b.Created, _ = r["created"].(time.Time)
+		b.Updated, _ = r["updated"].(time.Time)
+
+		if settings, ok := text(r["settings"]); ok {
+			if err := json.Unmarshal(settings, &b.Settings); err != nil {
+				return nil, errutil.With(err)
+			}
+		}
+
+		return &b, nil
+	}
+
+	return nil, errutil.Wrap(sql.ErrNoRows, sql.ErrNoRows)
+}
+
+// retention maps guilds to their retention_days override.
+func (t tables) retention() map[string]int {
+	retention := map[string]int{}
+	for _, r := range t[models.TableGuilds] {
+		raw, _ := text(r["settings"])
+		var settings struct {
+			RetentionDays *int `json:"retention_days"`
+		}
+		if err := json.Unmarshal(raw, &settings); err == nil && settings.RetentionDays != nil {
+			id, _ := r["id"].(string)
+			retention[id] = *settings.RetentionDays
+		}
+	}
+	return retention
+}
+
+func (m *Memory) PurgeInteractions(ctx context.Context, now time.Time, defaultDays, limit int, archive func([]models.ArchivedInteraction) error) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	retention := m.tables.retention()
+
+	var archived []models.ArchivedInteraction
+	var kept []row
+	for _, r := range m.tables[models.TableInteractions] {
+		guildID, _ := r["guild_id"].(string)
+		days, ok := retention[guildID]
+		if !ok {
+			days = defaultDays
+		}
+
+		created, _ := r["created"].(time.Time)
+		if len(archived) >= limit || days <= 0 || !created.Before(now.AddDate(0, 0, -days)) {
+			kept = append(kept, r)
+			continue
+		}
+
+		a := models.ArchivedInteraction{GuildID: guildID, Created: created}
+		a.ID, _ = r["id"].(string)
+		a.UserID, _ = r["user_id"].(string)
+		a.Command, _ = r["command"].(string)
+		a.Interaction, _ = text(r["interaction"])
+		archived = append(archived, a)
+	}
+
+	if len(archived) == 0 {
+		return 0, nil
+	}
+
+	if err := archive(archived); err != nil {
+		return 0, errutil.With(err)
+	}
+
+	m.tables[models.TableInteractions] = kept
+	return len(archived), nil
+}
+
+func (m *Memory) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+func (m *Memory) BeginTx(ctx context.Context) (Tx, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return &memoryTx{m: m, tables: m.tables.clone()}, nil
+}
+
+// memoryTx works against a snapshot so reads inside the transaction see its
+// own writes, and records each write so Commit can replay it onto the live
+// tables without discarding anything written outside the transaction.
+type memoryTx struct {
+	mu     sync.Mutex
+	m      *Memory
+	tables tables
+	ops    []func(tables) error
+	done   bool
+}
+
+func (tx *memoryTx) apply(op func(tables) error) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return sql.ErrTxDone
+	}
+
+	if err := op(tx.tables); err != nil {
+		return err
+	}
+	tx.ops = append(tx.ops, op)
+
+	return nil
+}
+
+func (tx *memoryTx) Commit() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return sql.ErrTxDone
+	}
+	tx.done = true
+
+	tx.m.mu.Lock()
+	defer tx.m.mu.Unlock()
+
+	live := tx.m.tables.clone()
+	for _, op := range tx.ops {
+		if err := op(live); err != nil {
+			return errutil.With(err)
+		}
+	}
+	tx.m.tables = live
+
+	return nil
+}
+
+func (tx *memoryTx) Rollback() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return sql.ErrTxDone
+	}
+	tx.done = true
+
+	return nil
+}
+
+func (tx *memoryTx) Create(ctx context.Context, m models.Mappable) error {
+	return tx.apply(func(t tables) error {
+		t.create(m)
+		return nil
+	})
+}
+
+func (tx *memoryTx) CreateMany(ctx context.Context, ms []models.Mappable) error {
+	return tx.apply(func(t tables) error {
+		return t.createMany(ms)
+	})
+}
+
+func (tx *memoryTx) Update(ctx context.Context, table models.Table, where sq.Eq, updates map[string]any) error {
+	return tx.apply(func(t tables) error {
+		return t.update(table, where, updates)
+	})
+}
+
+func (tx *memoryTx) Delete(ctx context.Context, table models.Table, where sq.Eq) error {
+	return tx.apply(func(t tables) error {
+		t.delete(table, where)
+		return nil
+	})
+}
+
+func (tx *memoryTx) Count(ctx context.Context, table models.Table, where sq.Eq) (int, error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return 0, sql.ErrTxDone
+	}
+
+	return tx.tables.count(table, where), nil
+}
+
+func (m *Memory) RollupUsage(ctx context.Context, day time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 1)
+
+	type key struct{ guild, command string }
+	commands := map[key]*models.CommandUsage{}
+	commandUsers := map[key]map[string]bool{}
+	guilds := map[string]int{}
+	guildUsers := map[string]map[string]bool{}
+	type userKey struct{ guild, user string }
+	users := map[userKey]int{}
+
+	for _, r := range m.tables[models.TableInteractions] {
+		guildID, _ := r["guild_id"].(string)
+		created, _ := r["created"].(time.Time)
+		if guildID == "" || created.Before(start) || !created.Before(end) {
+			continue
+		}
+
+		userID, _ := r["user_id"].(string)
+		guilds[guildID]++
+		if guildUsers[guildID] == nil {
+			guildUsers[guildID] = map[string]bool{}
+		}
+		if userID != "" {
+			guildUsers[guildID][userID] = true
+			users[userKey{guildID, userID}]++
+		}
+
+		command, _ := r["command"].(string)
+		if command == "" {
+			continue
+		}
+
+		k := key{guildID, command}
+		if commands[k] == nil {
+			commands[k] = &models.CommandUsage{Command: command}
+			commandUsers[k] = map[string]bool{}
+		}
+		commands[k].Uses++
+		if userID != "" {
+			commandUsers[k][userID] = true
+		}
+		switch r["outcome"] {
+		case models.OutcomeFailure:
+			commands[k].Failures++
+		case models.OutcomeError:
+			commands[k].Errors++
+		}
+	}
+
+	for k, u := range commands {
+		m.tables.upsert(models.TableCommandUsageDaily, sq.Eq{"guild_id": k.guild, "day": start, "command": k.command}, row{
+			"uses":     u.Uses,
+			"users":    len(commandUsers[k]),
+			"failures": u.Failures,
+			"errors":   u.Errors,
+		})
+	}
+	for guildID, n := range guilds {
+		m.tables.upsert(models.TableGuildUsageDaily, sq.Eq{"guild_id": guildID, "day": start}, row{
+			"interactions": n,
+			"users":        len(guildUsers[guildID]),
+		})
+	}
+	for k, n := range users {
+		m.tables.upsert(models.TableUserUsageDaily, sq.Eq{"guild_id": k.guild, "day": start, "user_id": k.user}, row{
+			"interactions": n,
+		})
+	}
+
+	return nil
+}
+
+func (m *Memory) LastRollup(ctx context.Context) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var last time.Time
+	for _, r := range m.tables[models.TableGuildUsageDaily] {
+		if day, _ := r["day"].(time.Time); day.After(last) {
+			last = day
+		}
+	}
+	if !last.IsZero() {
+		return last, nil
+	}
+
+	for _, r := range m.tables[models.TableInteractions] {
+		if created, _ := r["created"].(time.Time); last.IsZero() || created.Before(last) {
+			last = created
+		}
+	}
+	if last.IsZero() {
+		return last, nil
+	}
+
+	return time.Date(last.Year(), last.Month(), last.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+func (m *Memory) TotalInteractions(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var last time.Time
+	for _, r := range m.tables[models.TableGuildUsageDaily] {
+		if day, _ := r["day"].(time.Time); day.After(last) {
+			last = day
+		}
+	}
+
+	var total int
+	for _, r := range m.tables[models.TableGuildUsageDaily] {
+		if day, _ := r["day"].(time.Time); day.Before(last) {
+			n, _ := r["interactions"].(int)
+			total += n
+		}
+	}
+	for _, r := range m.tables[models.TableInteractions] {
+		created, _ := r["created"].(time.Time)
+		if guildID, _ := r["guild_id"].(string); guildID != "" && !created.Before(last) {
+			total++
+		}
+	}
+
+	return total, nil
+}
+
+func (m *Memory) CommandUsage(ctx context.Context, guildID string, since time.Time) ([]models.CommandUsage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	since = time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, time.UTC)
+	totals := map[string]*models.CommandUsage{}
+	for _, r := range m.tables[models.TableCommandUsageDaily] {
+		day, _ := r["day"].(time.Time)
+		if !equal(r["guild_id"], guildID) || day.Before(since) {
+			continue
+		}
+
+		command, _ := r["command"].(string)
+		if totals[command] == nil {
+			totals[command] = &models.CommandUsage{Command: command}
+		}
+		uses, _ := r["uses"].(int)
+		failures, _ := r["failures"].(int)
+		errors, _ := r["errors"].(int)
+		totals[command].Uses += uses
+		totals[command].Failures += failures
+		totals[command].Errors += errors
+	}
+
+	usage := make([]models.CommandUsage, 0, len(totals))
+	for _, u := range totals {
+		usage = append(usage, *u)
+	}
+	slices.SortFunc(usage, func(a, b models.CommandUsage) int {
+		if a.Uses != b.Uses {
+			return b.Uses - a.Uses
+		}
+		return strings.Compare(a.Command, b.Command)
+	})
+
+	return usage, nil
+}
+
+func (m *Memory) DailyUsage(ctx context.Context, guildID string, since time.Time) ([]models.DailyUsage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	since = time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, time.UTC)
+	var usage []models.DailyUsage
+	for _, r := range m.tables[models.TableGuildUsageDaily] {
+		day, _ := r["day"].(time.Time)
+		if !equal(r["guild_id"], guildID) || day.Before(since) {
+			continue
+		}
+
+		u := models.DailyUsage{Day: day}
+		u.Interactions, _ = r["interactions"].(int)
+		u.Users, _ = r["users"].(int)
+		usage = append(usage, u)
+	}
+	slices.SortFunc(usage, func(a, b models.DailyUsage) int { return a.Day.Compare(b.Day) })
+
+	return usage, nil
+}
+
+func (m *Memory) UniqueUsers(ctx context.Context, guildID string, since time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	since = time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, time.UTC)
+	users := map[string]bool{}
+	for _, r := range m.tables[models.TableUserUsageDaily] {
+		day, _ := r["day"].(time.Time)
+		userID, _ := r["user_id"].(string)
+		if equal(r["guild_id"], guildID) && !day.Before(since) {
+			users[userID] = true
+		}
+	}
+
+	return len(users), nil
+}
+
+func (m *Memory) OpenVoiceSession(ctx context.Context, guildID, userID string) (*models.VoiceSession, error) {
+	sessions := m.voiceSessions(sq.Eq{"guild_id": guildID, "user_id": userID, "ended": nil}, 1)
+	if len(sessions) == 0 {
+		return nil, errutil.Wrap(sql.ErrNoRows, sql.ErrNoRows)
+	}
+
+	return &sessions[0], nil
+}
+
+func (m *Memory) OpenVoiceSessions(ctx context.Context, guildID string) ([]models.VoiceSession, error) {
+	return m.voiceSessions(sq.Eq{"guild_id": guildID, "ended": nil}, 0), nil
+}
+
+func (m *Memory) LastVoiceEvent(ctx context.Context, guildID string) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var last time.Time
+	for _, r := range m.tables[models.TableVoiceEvents] {
+		created, _ := r["created"].(time.Time)
+		if equal(r["guild_id"], guildID) && created.After(last) {
+			last = created
+		}
+	}
+
+	return last, nil
+}
+
+func (m *Memory) PurgeVoiceEvents(ctx context.Context, now time.Time, defaultDays, limit int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	retention := m.tables.retention()
+
+	var purged int
+	var kept []row
+	for _, r := range m.tables[models.TableVoiceEvents] {
+		guildID, _ := r["guild_id"].(string)
+		days, ok := retention[guildID]
+		if !ok {
+			days = defaultDays
+		}
+
+		created, _ := r["created"].(time.Time)
+		if purged >= limit || days <= 0 || !created.Before(now.AddDate(0, 0, -days)) {
+			kept = append(kept, r)
+			continue
+		}
+		purged++
+	}
+
+	m.tables[models.TableVoiceEvents] = kept
+	return purged, nil
+}
+
+func (m *Memory) VoiceHistory(ctx context.Context, guildID, userID string, limit int) ([]models.VoiceSession, error) {
+	return m.voiceSessions(sq.Eq{"guild_id": guildID, "user_id": userID}, limit), nil
+}
+
+func (m *Memory) voiceSessions(where sq.Eq, limit int) []models.VoiceSession {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var sessions []models.VoiceSession
+	for _, r := range m.tables[models.TableVoiceSessions] {
+		if !r.matches(where) {
+			continue
+		}
+
+		var s models.VoiceSession
+		s.GuildID, _ = r["guild_id"].(string)
+		s.UserID, _ = r["user_id"].(string)
+		s.ChannelID, _ = r["channel_id"].(string)
+		s.Muted, _ = r["muted"].(bool)
+		s.Deafened, _ = r["deafened"].(bool)
+		s.Created, _ = r["created"].(time.Time)
+		if ended, ok := r["ended"].(time.Time); ok {
+			s.Ended = &ended
+		}
+		sessions = append(sessions, s)
+	}
+
+	slices.SortStableFunc(sessions, func(a, b models.VoiceSession) int { return b.Created.Compare(a.Created) })
+	if limit > 0 && len(sessions) > limit {
+		sessions = sessions[:limit]
+	}
+
+	return sessions
+}
+
+func (m *Memory) VoiceLeaderboard(ctx context.Context, guildID string, since time.Time, limit int) ([]models.VoiceTotal, error) {
+	now := time.Now().UTC()
+
+	totals := map[string]*models.VoiceTotal{}
+	for _, s := range m.voiceSessions(sq.Eq{"guild_id": guildID}, 0) {
+		if s.Created.Before(since) {
+			continue
+		}
+		if totals[s.UserID] == nil {
+			totals[s.UserID] = &models.VoiceTotal{UserID: s.UserID}
+		}
+		totals[s.UserID].Sessions++
+		totals[s.UserID].Duration += s.Duration(now)
+	}
+
+	leaderboard := make([]models.VoiceTotal, 0, len(totals))
+	for _, t := range totals {
+		leaderboard = append(leaderboard, *t)
+	}
+	slices.SortFunc(leaderboard, func(a, b models.VoiceTotal) int {
+		if a.Duration != b.Duration {
+			return int(b.Duration - a.Duration)
+		}
+		return strings.Compare(a.UserID, b.UserID)
+	})
+	if len(leaderboard) > limit {
+		leaderboard = leaderboard[:limit]
+	}
+
+	return leaderboard, nil
+}
@@ -0,0 +1,280 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/glotchimo/recast/internal/models"
+)
+
+const testTable models.Table = "things"
+
+type thing map[string]any
+
+func (t thing) Table() models.Table { return testTable }
+func (t thing) Map() map[string]any { return map[string]any(t) }
+
+func TestMemoryCount(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+
+	var note *string
+	for _, r := range []thing{
+		{"id": "1", "kind": "a", "raw": []byte("x"), "note": nil},
+		{"id": "2", "kind": "b", "raw": "x", "note": note},
+		{"id": "3", "kind": "a", "raw": []byte("y"), "note": "set"},
+	} {
+		if err := m.Create(ctx, r); err != nil {
+			t.Fatalf("error creating row: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		where sq.Eq
+		want  int
+	}{
+		{name: "everything", where: sq.Eq{}, want: 3},
+		{name: "equal", where: sq.Eq{"kind": "a"}, want: 2},
+		{name: "several columns", where: sq.Eq{"kind": "a", "id": "3"}, want: 1},
+		{name: "in", where: sq.Eq{"id": []string{"1", "2", "4"}}, want: 2},
+		{name: "empty in", where: sq.Eq{"id": []string{}}, want: 0},
+		{name: "bytes equal strings", where: sq.Eq{"raw": "x"}, want: 2},
+		{name: "bytes aren't a list", where: sq.Eq{"raw": []byte("y")}, want: 1},
+		{name: "null", where: sq.Eq{"note": nil}, want: 2},
+		{name: "missing column is null", where: sq.Eq{"other": nil}, want: 3},
+		{name: "no match", where: sq.Eq{"kind": "c"}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := m.Count(ctx, testTable, tt.where)
+			if err != nil {
+				t.Fatalf("Count() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Count(%v) = %d, want %d", tt.where, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryDelete(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("hard", func(t *testing.T) {
+		m := NewMemory()
+		for _, id := range []string{"1", "2"} {
+			if err := m.Create(ctx, thing{"id": id}); err != nil {
+				t.Fatalf("error creating row: %v", err)
+			}
+		}
+
+		if err := m.Delete(ctx, testTable, sq.Eq{"id": "1"}); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		if n, _ := m.Count(ctx, testTable, sq.Eq{}); n != 1 {
+			t.Errorf("got %d rows after deleting, want 1", n)
+		}
+	})
+
+	t.Run("soft", func(t *testing.T) {
+		m := NewMemory()
+		for _, id := range []string{"1", "2"} {
+			if err := m.PutGuild(ctx, models.Guild{ID: id, Name: "guild " + id}); err != nil {
+				t.Fatalf("error creating guild: %v", err)
+			}
+		}
+
+		if err := m.Delete(ctx, models.TableGuilds, sq.Eq{"id": "1"}); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+
+		deleted, err := m.GetGuild(ctx, "1")
+		if err != nil {
+			t.Fatalf("GetGuild() error = %v", err)
+		}
+		if deleted.Deleted == nil {
+			t.Error("deleted guild has no deleted time")
+		}
+
+		kept, err := m.GetGuild(ctx, "2")
+		if err != nil {
+			t.Fatalf("GetGuild() error = %v", err)
+		}
+		if kept.Deleted != nil {
+			t.Errorf("untouched guild was deleted at %s", kept.Deleted)
+		}
+	})
+}
+
+func TestMemoryUpdate(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+
+	g := models.Guild{ID: "1"}
+	g.Settings.LogChannelID = "10"
+	if err := m.PutGuild(ctx, g); err != nil {
+		t.Fatalf("error creating guild: %v", err)
+	}
+
+	if err := m.Update(ctx, models.TableGuilds, sq.Eq{"id": "1"}, map[string]any{
+		"name":     "renamed",
+		"settings": SetJSON("settings", models.CommandSettings{Alias: "pong"}, "commands", "ping"),
+	}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	got, err := m.GetGuild(ctx, "1")
+	if err != nil {
+		t.Fatalf("GetGuild() error = %v", err)
+	}
+	if got.Name != "renamed" {
+		t.Errorf("got name %q, want renamed", got.Name)
+	}
+	if got.Settings.LogChannelID != "10" {
+		t.Errorf("got log channel %q, want the untouched 10", got.Settings.LogChannelID)
+	}
+	if alias := got.Settings.Commands["ping"].Alias; alias != "pong" {
+		t.Errorf("got alias %q, want pong", alias)
+	}
+
+	if err := m.Update(ctx, models.TableGuilds, sq.Eq{"id": "1"}, map[string]any{"name": sq.Expr("upper(name)")}); err == nil {
+		t.Error("Update() with an unsupported expression error = nil, want an error")
+	}
+}
+
+func TestMemoryGetMissing(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+
+	if _, err := m.GetGuild(ctx, "1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetGuild() error = %v, want sql.ErrNoRows", err)
+	}
+	if _, err := m.GetBot(ctx, "1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetBot() error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestMemoryCreateMany(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+
+	created := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	if err := m.CreateMany(ctx, []models.Mappable{thing{"id": "1", "created": created}, thing{"id": "2"}}); err != nil {
+		t.Fatalf("CreateMany() error = %v", err)
+	}
+	if n, _ := m.Count(ctx, testTable, sq.Eq{"created": created}); n != 1 {
+		t.Errorf("got %d rows keeping their created time, want 1", n)
+	}
+	if n, _ := m.Count(ctx, testTable, sq.Eq{}); n != 2 {
+		t.Errorf("got %d rows, want 2", n)
+	}
+
+	mixed := []models.Mappable{thing{"id": "3"}, models.Guild{ID: "1"}}
+	if err := m.CreateMany(ctx, mixed); err == nil {
+		t.Error("CreateMany() of mixed tables error = nil, want an error")
+	}
+	if n, _ := m.Count(ctx, testTable, sq.Eq{}); n != 2 {
+		t.Errorf("got %d rows after a failed batch, want 2", n)
+	}
+}
+
+func TestMemoryTx(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("commit", func(t *testing.T) {
+		m := NewMemory()
+		if err := m.Create(ctx, thing{"id": "1", "kind": "a"}); err != nil {
+			t.Fatalf("error creating row: %v", err)
+		}
+
+		tx, err := m.BeginTx(ctx)
+		if err != nil {
+			t.Fatalf("BeginTx() error = %v", err)
+		}
+		if err := tx.Create(ctx, thing{"id": "2", "kind": "a"}); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if err := tx.Update(ctx, testTable, sq.Eq{"kind": "a"}, map[string]any{"kind": "b"}); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+
+		// The transaction sees its own writes, the store doesn't yet.
+		if n, _ := tx.Count(ctx, testTable, sq.Eq{"kind": "b"}); n != 2 {
+			t.Errorf("got %d updated rows inside the transaction, want 2", n)
+		}
+		if n, _ := m.Count(ctx, testTable, sq.Eq{}); n != 1 {
+			t.Errorf("got %d rows outside the transaction, want 1", n)
+		}
+
+		// Writes made outside the transaction survive the commit, and the
+		// transaction's update is replayed against them.
+		if err := m.Create(ctx, thing{"id": "3", "kind": "a"}); err != nil {
+			t.Fatalf("error creating row: %v", err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Commit() error = %v", err)
+		}
+		if n, _ := m.Count(ctx, testTable, sq.Eq{"kind": "b"}); n != 3 {
+			t.Errorf("got %d updated rows after committing, want 3", n)
+		}
+
+		if err := tx.Commit(); !errors.Is(err, sql.ErrTxDone) {
+			t.Errorf("second Commit() error = %v, want sql.ErrTxDone", err)
+		}
+		if err := tx.Create(ctx, thing{"id": "4"}); !errors.Is(err, sql.ErrTxDone) {
+			t.Errorf("Create() after Commit() error = %v, want sql.ErrTxDone", err)
+		}
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		m := NewMemory()
+
+		tx, err := m.BeginTx(ctx)
+		if err != nil {
+			t.Fatalf("BeginTx() error = %v", err)
+		}
+		if err := tx.Create(ctx, thing{"id": "1"}); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if err := tx.Rollback(); err != nil {
+			t.Fatalf("Rollback() error = %v", err)
+		}
+
+		if n, _ := m.Count(ctx, testTable, sq.Eq{}); n != 0 {
+			t.Errorf("got %d rows after rolling back, want 0", n)
+		}
+		if _, err := tx.Count(ctx, testTable, sq.Eq{}); !errors.Is(err, sql.ErrTxDone) {
+			t.Errorf("Count() after Rollback() error = %v, want sql.ErrTxDone", err)
+		}
+	})
+
+	t.Run("failed write", func(t *testing.T) {
+		m := NewMemory()
+
+		tx, err := m.BeginTx(ctx)
+		if err != nil {
+			t.Fatalf("BeginTx() error = %v", err)
+		}
+		if err := tx.CreateMany(ctx, []models.Mappable{thing{"id": "1"}, models.Guild{ID: "1"}}); err == nil {
+			t.Fatal("CreateMany() of mixed tables error = nil, want an error")
+		}
+		if err := tx.Create(ctx, thing{"id": "2"}); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Commit() error = %v", err)
+		}
+
+		// Only the write that succeeded is replayed.
+		if n, _ := m.Count(ctx, testTable, sq.Eq{}); n != 1 {
+			t.Errorf("got %d rows after committing, want 1", n)
+		}
+	})
+}
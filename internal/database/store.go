@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/glotchimo/recast/internal/models"
+)
+
+type Querier interface {
+	Create(ctx context.Context, m models.Mappable) error
+	CreateMany(ctx context.Context, ms []models.Mappable) error
+	Update(ctx context.Context, table models.Table, where sq.Eq, updates map[string]any) error
+	Delete(ctx context.Context, table models.Table, where sq.Eq) error
+	Count(ctx context.Context, table models.Table, where sq.Eq) (int, error)
+}
+
+type Analytics interface {
+	CommandUsage(ctx context.Context, guildID string, since time.Time) ([]models.CommandUsage, error)
+	DailyUsage(ctx context.Context, guildID string, since time.Time) ([]models.DailyUsage, error)
+	UniqueUsers(ctx context.Context, guildID string, since time.Time) (int, error)
+}
+
+type Voice interface {
+	VoiceLeaderboard(ctx context.Context, guildID string, since time.Time, limit int) ([]models.VoiceTotal, error)
+	VoiceHistory(ctx context.Context, guildID, userID string, limit int) ([]models.VoiceSession, error)
+}
+
+type Store interface {
+	Querier
+	Analytics
+	Voice
+	GetGuild(ctx context.Context, id string) (*models.Guild, error)
+	PutGuild(ctx context.Context, guild models.Guild) error
+	BeginTx(ctx context.Context) (Tx, error)
+}
+
+type Tx interface {
+	Querier
+	Commit() error
+	Rollback() error
+}
+
+var (
+	_ Store = (*Database)(nil)
+	_ Store = (*Memory)(nil)
+)
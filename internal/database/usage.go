@@ -0,0 +1,222 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/glotchimo/recast/internal/metrics"
+	"github.com/glotchimo/recast/internal/models"
+	"github.com/glotchimo/recast/internal/tracing"
+	"github.com/graxinc/errutil"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const dateLayout = "2006-01-02"
+
+func (db *Database) RollupUsage(ctx context.Context, day time.Time) error {
+	defer metrics.ObserveQuery("rollup", string(models.TableGuildUsageDaily))()
+	ctx, span := tracing.Start(ctx, "db.rollup", attribute.String("db.table", string(models.TableGuildUsageDaily)), attribute.String("day", day.Format(dateLayout)))
+	defer span.End()
+
+	date := day.Format(dateLayout)
+	window := sq.And{
+		sq.NotEq{"guild_id": nil},
+		sq.Expr("created >= ?::date", date),
+		sq.Expr("created < ?::date + 1", date),
+	}
+
+	commands := db.builder.
+		Insert(string(models.TableCommandUsageDaily)).
+		Columns("guild_id", "day", "command", "uses", "users", "failures", "errors").
+		Select(sq.
+			Select(
+				"guild_id",
+				"created::date",
+				"command",
+				"COUNT(*)",
+				"COUNT(DISTINCT user_id)",
+				"COUNT(*) FILTER (WHERE outcome = 'failure')",
+				"COUNT(*) FILTER (WHERE outcome = 'error')").
+			From(string(models.TableInteractions)).
+			Where(window).
+			Where(sq.NotEq{"command": nil}).
+			GroupBy("guild_id", "created::date", "command")).
+		Suffix(`ON CONFLICT (guild_id, day, command) DO UPDATE SET
+			uses = EXCLUDED.uses, users = EXCLUDED.users, failures = EXCLUDED.failures, errors = EXCLUDED.errors`)
+
+	guilds := db.builder.
+		Insert(string(models.TableGuildUsageDaily)).
+		Columns("guild_id", "day", "interactions", "users").
+		Select(sq.
+			Select("guild_id", "created::date", "COUNT(*)", "COUNT(DISTINCT user_id)").
+			From(string(models.TableInteractions)).
+			Where(window).
+			GroupBy("guild_id", "created::date")).
+		Suffix(`ON CONFLICT (guild_id, day) DO UPDATE SET
+			interactions = EXCLUDED.interactions, users = EXCLUDED.users`)
+
+	users := db.builder.
+		Insert(string(models.TableUserUsageDaily)).
+		Columns("guild_id", "day", "user_id", "interactions").
+		Select(sq.
+			Select("guild_id", "created::date", "user_id", "COUNT(*)").
+			From(string(models.TableInteractions)).
+			Where(window).
+			Where(sq.NotEq{"user_id": nil}).
+			GroupBy("guild_id", "created::date", "user_id")).
+		Suffix(`ON CONFLICT (guild_id, day, user_id) DO UPDATE SET
+			interactions = EXCLUDED.interactions`)
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errutil.With(err)
+	}
+	defer tx.Rollback()
+
+	if _, err := commands.RunWith(tx).ExecContext(ctx); err != nil {
+		return errutil.With(err)
+	}
+	if _, err := guilds.RunWith(tx).ExecContext(ctx); err != nil {
+		return errutil.With(err)
+	}
+	if _, err := users.RunWith(tx).ExecContext(ctx); err != nil {
+		return errutil.With(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errutil.With(err)
+	}
+
+	return nil
+}
+
+func (db *Database) LastRollup(ctx context.Context) (time.Time, error) {
+	defer metrics.ObserveQuery("get", string(models.TableGuildUsageDaily))()
+	ctx, span := tracing.Start(ctx, "db.get", attribute.String("db.table", string(models.TableGuildUsageDaily)))
+	defer span.End()
+
+	var last sql.NullTime
+	q := db.builder.
+		Select("COALESCE(MAX(day), (SELECT MIN(created)::date FROM " + string(models.TableInteractions) + "))").
+		From(string(models.TableGuildUsageDaily))
+
+	if err := q.QueryRowContext(ctx).Scan(&last); err != nil {
+		return time.Time{}, errutil.With(err)
+	}
+
+	return last.Time, nil
+}
+
+// TotalInteractions counts guild interactions from the rollups, which outlive
+// retention, and from the raw table for the latest rolled-up day onwards,
+// since that day is rolled up again until it's over.
+func (db *Database) TotalInteractions(ctx context.Context) (int, error) {
+	defer metrics.ObserveQuery("count", string(models.TableGuildUsageDaily))()
+	ctx, span := tracing.Start(ctx, "db.count", attribute.String("db.table", string(models.TableGuildUsageDaily)))
+	defer span.End()
+
+	// One statement, so a rollup finishing in between can't count a day twice.
+	q := db.builder.
+		Select(
+			fmt.Sprintf("(SELECT COALESCE(SUM(interactions), 0) FROM %s WHERE day < last.day)", models.TableGuildUsageDaily),
+			fmt.Sprintf("(SELECT COUNT(*) FROM %s WHERE guild_id IS NOT NULL AND created >= COALESCE(last.day, '-infinity'))", models.TableInteractions)).
+		FromSelect(db.builder.Select("MAX(day) AS day").From(string(models.TableGuildUsageDaily)), "last")
+
+	var rolled, raw int
+	if err := q.QueryRowContext(ctx).Scan(&rolled, &raw); err != nil {
+		return 0, errutil.With(err)
+	}
+
+	return rolled + raw, nil
+}
+
+func (db *Database) CommandUsage(ctx context.Context, guildID string, since time.Time) ([]models.CommandUsage, error) {
+	defer metrics.ObserveQuery("select", string(models.TableCommandUsageDaily))()
+	ctx, span := tracing.Start(ctx, "db.select", attribute.String("db.table", string(models.TableCommandUsageDaily)))
+	defer span.End()
+
+	q := db.builder.
+		Select("command", "SUM(uses)", "SUM(failures)", "SUM(errors)").
+		From(string(models.TableCommandUsageDaily)).
+		Where(sq.Eq{"guild_id": guildID}).
+		Where(sq.Expr("day >= ?::date", since.Format(dateLayout))).
+		GroupBy("command").
+		OrderBy("SUM(uses) DESC", "command")
+
+	rows, err := q.QueryContext(ctx)
+	if err != nil {
+		return nil, errutil.With(err)
+	}
+	defer rows.Close()
+
+	var usage []models.CommandUsage
+	for rows.Next() {
+		var u models.CommandUsage
+		if err := rows.Scan(&u.Command, &u.Uses, &u.Failures, &u.Errors); err != nil {
+			return nil, errutil.With(err)
+		}
+		usage = append(usage, u)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errutil.With(err)
+	}
+
+	return usage, nil
+}
+
+func (db *Database) DailyUsage(ctx context.Context, guildID string, since time.Time) ([]models.DailyUsage, error) {
+	defer metrics.ObserveQuery("select", string(models.TableGuildUsageDaily))()
+	ctx, span := tracing.Start(ctx, "db.select", attribute.String("db.table", string(models.TableGuildUsageDaily)))
+	defer span.End()
+
+	q := db.builder.
+		Select("day", "interactions", "users").
+		From(string(models.TableGuildUsageDaily)).
+		Where(sq.Eq{"guild_id": guildID}).
+		Where(sq.Expr("day >= ?::date", since.Format(dateLayout))).
+		OrderBy("day")
+
+	rows, err := q.QueryContext(ctx)
+	if err != nil {
+		return nil, errutil.With(err)
+	}
+	defer rows.Close()
+
+	var usage []models.DailyUsage
+	for rows.Next() {
+		var u models.DailyUsage
+		if err := rows.Scan(&u.Day, &u.Interactions, &u.Users); err != nil {
+			return nil, errutil.With(err)
+		}
+		usage = append(usage, u)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errutil.With(err)
+	}
+
+	return usage, nil
+}
+
+func (db *Database) UniqueUsers(ctx context.Context, guildID string, since time.Time) (int, error) {
+	defer metrics.ObserveQuery("count", string(models.TableUserUsageDaily))()
+	ctx, span := tracing.Start(ctx, "db.count", attribute.String("db.table", string(models.TableUserUsageDaily)))
+	defer span.End()
+
+	var users int
+	q := db.builder.
+		Select("COUNT(DISTINCT user_id)").
+		From(string(models.TableUserUsageDaily)).
+		Where(sq.Eq{"guild_id": guildID}).
+		Where(sq.Expr("day >= ?::date", since.Format(dateLayout)))
+
+	if err := q.QueryRowContext(ctx).Scan(&users); err != nil {
+		return 0, errutil.With(err)
+	}
+
+	return users, nil
+}
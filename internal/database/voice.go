@@ -0,0 +1,159 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/glotchimo/recast/internal/metrics"
+	"github.com/glotchimo/recast/internal/models"
+	"github.com/glotchimo/recast/internal/tracing"
+	"github.com/graxinc/errutil"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var voiceSessionColumns = []string{"guild_id", "user_id", "channel_id", "muted", "deafened", "created", "ended"}
+
+func (db *Database) OpenVoiceSession(ctx context.Context, guildID, userID string) (*models.VoiceSession, error) {
+	sessions, err := db.voiceSessions(ctx, sq.Eq{"guild_id": guildID, "user_id": userID, "ended": nil}, 1)
+	if err != nil {
+		return nil, errutil.With(err)
+	}
+	if len(sessions) == 0 {
+		return nil, errutil.Wrap(sql.ErrNoRows, sql.ErrNoRows)
+	}
+
+	return &sessions[0], nil
+}
+
+func (db *Database) OpenVoiceSessions(ctx context.Context, guildID string) ([]models.VoiceSession, error) {
+	return db.voiceSessions(ctx, sq.Eq{"guild_id": guildID, "ended": nil}, 0)
+}
+
+func (db *Database) VoiceHistory(ctx context.Context, guildID, userID string, limit int) ([]models.VoiceSession, error) {
+	return db.voiceSessions(ctx, sq.Eq{"guild_id": guildID, "user_id": userID}, limit)
+}
+
+func (db *Database) voiceSessions(ctx context.Context, where sq.Eq, limit int) ([]models.VoiceSession, error) {
+	defer metrics.ObserveQuery("select", string(models.TableVoiceSessions))()
+	ctx, span := tracing.Start(ctx, "db.select", attribute.String("db.table", string(models.TableVoiceSessions)))
+	defer span.End()
+
+	q := db.builder.
+		Select(voiceSessionColumns...).
+		From(string(models.TableVoiceSessions)).
+		Where(where).
+		OrderBy("created DESC")
+	if limit > 0 {
+		q = q.Limit(uint64(limit))
+	}
+
+	rows, err := q.QueryContext(ctx)
+	if err != nil {
+		return nil, errutil.With(err)
+	}
+	defer rows.Close()
+
+	var sessions []models.VoiceSession
+	for rows.Next() {
+		var s models.VoiceSession
+		if err := rows.Scan(&s.GuildID, &s.UserID, &s.ChannelID, &s.Muted, &s.Deafened, &s.Created, &s.Ended); err != nil {
+			return nil, errutil.With(err)
+		}
+		sessions = append(sessions, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errutil.With(err)
+	}
+
+	return sessions, nil
+}
+
+func (db *Database) VoiceLeaderboard(ctx context.Context, guildID string, since time.Time, limit int) ([]models.VoiceTotal, error) {
+	defer metrics.ObserveQuery("select", string(models.TableVoiceSessions))()
+	ctx, span := tracing.Start(ctx, "db.select", attribute.String("db.table", string(models.TableVoiceSessions)))
+	defer span.End()
+
+	q := db.builder.
+		Select("user_id", "COUNT(*)").
+		Column(sq.Expr("SUM(COALESCE(duration, EXTRACT(EPOCH FROM ?::timestamp - created)::int)) AS seconds", time.Now().UTC())).
+		From(string(models.TableVoiceSessions)).
+		Where(sq.Eq{"guild_id": guildID}).
+		Where(sq.GtOrEq{"created": since.UTC()}).
+		GroupBy("user_id").
+		OrderBy("seconds DESC", "user_id").
+		Limit(uint64(limit))
+
+	rows, err := q.QueryContext(ctx)
+	if err != nil {
+		return nil, errutil.With(err)
+	}
+	defer rows.Close()
+
+	var totals []models.VoiceTotal
+	for rows.Next() {
+		var t models.VoiceTotal
+		var seconds int64
+		if err := rows.Scan(&t.UserID, &t.Sessions, &seconds); err != nil {
+			return nil, errutil.With(err)
+		}
+		t.Duration = time.Duration(seconds) * time.Second
+		totals = append(totals, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errutil.With(err)
+	}
+
+	return totals, nil
+}
+
+func (db *Database) LastVoiceEvent(ctx context.Context, guildID string) (time.Time, error) {
+	defer metrics.ObserveQuery("select", string(models.TableVoiceEvents))()
+	ctx, span := tracing.Start(ctx, "db.select", attribute.String("db.table", string(models.TableVoiceEvents)))
+	defer span.End()
+
+	var last sql.NullTime
+	q := db.builder.
+		Select("MAX(created)").
+		From(string(models.TableVoiceEvents)).
+		Where(sq.Eq{"guild_id": guildID})
+
+	if err := q.QueryRowContext(ctx).Scan(&last); err != nil {
+		return time.Time{}, errutil.With(err)
+	}
+
+	return last.Time, nil
+}
+
+func (db *Database) PurgeVoiceEvents(ctx context.Context, now time.Time, defaultDays, limit int) (int, error) {
+	defer metrics.ObserveQuery("purge", string(models.TableVoiceEvents))()
+	ctx, span := tracing.Start(ctx, "db.purge", attribute.String("db.table", string(models.TableVoiceEvents)))
+	defer span.End()
+
+	days := sq.Expr("COALESCE((g.settings->>'retention_days')::int, ?)", defaultDays)
+	expired := sq.
+		Select("e.ctid").
+		From(string(models.TableVoiceEvents) + " e").
+		LeftJoin(string(models.TableGuilds) + " g ON g.id = e.guild_id").
+		Where(sq.Expr("? > 0", days)).
+		Where(sq.Expr("e.created < ?::timestamp - make_interval(days => ?)", now, days)).
+		Limit(uint64(limit))
+
+	res, err := db.builder.
+		Delete(string(models.TableVoiceEvents)).
+		Where(sq.Expr("ctid IN (?)", expired)).
+		ExecContext(ctx)
+	if err != nil {
+		return 0, errutil.With(err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, errutil.With(err)
+	}
+
+	return int(n), nil
+}
@@ -0,0 +1,251 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/glotchimo/recast/internal/metrics"
+	"github.com/glotchimo/recast/internal/models"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultBatchSize     = 100
+	defaultFlushInterval = 5 * time.Second
+	defaultMaxBuffered   = 10000
+	defaultFlushTimeout  = 10 * time.Second
+	maxRetryBackoff      = time.Minute
+	maxBatchRetries      = 5
+)
+
+type WriterConfig struct {
+	BatchSize     int
+	FlushInterval time.Duration
+	MaxBuffered   int
+	FlushTimeout  time.Duration
+}
+
+type Writer struct {
+	l     *slog.Logger
+	q     Querier
+	table models.Table
+	conf  WriterConfig
+
+	rows    chan models.Mappable
+	flushes chan chan error
+	stop    chan struct{}
+	done    chan struct{}
+
+	buffer   []models.Mappable
+	failures int
+	retryAt  time.Time
+	buffered atomic.Int64
+}
+
+func NewWriter(l *slog.Logger, q Querier, table models.Table, conf WriterConfig) *Writer {
+	if conf.BatchSize <= 0 {
+		conf.BatchSize = defaultBatchSize
+	}
+	if conf.FlushInterval <= 0 {
+		conf.FlushInterval = defaultFlushInterval
+	}
+	if conf.MaxBuffered < conf.BatchSize {
+		conf.MaxBuffered = max(defaultMaxBuffered, conf.BatchSize)
+	}
+	if conf.FlushTimeout <= 0 {
+		conf.FlushTimeout = defaultFlushTimeout
+	}
+
+	w := &Writer{
+		l:       l,
+		q:       q,
+		table:   table,
+		conf:    conf,
+		rows:    make(chan models.Mappable, conf.MaxBuffered),
+		flushes: make(chan chan error),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	go w.run()
+
+	return w
+}
+
+func (w *Writer) Write(m models.Mappable) {
+	select {
+	case <-w.stop:
+		metrics.RowsWritten.WithLabelValues(string(w.table), "dropped").Inc()
+		w.l.Warn("dropped row written after writer closed", "table", w.table)
+		return
+	default:
+	}
+
+	select {
+	case w.rows <- m:
+	default:
+		metrics.RowsWritten.WithLabelValues(string(w.table), "dropped").Inc()
+		w.l.Warn("writer queue full, dropped row", "table", w.table)
+	}
+}
+
+func (w *Writer) Flush(ctx context.Context) error {
+	result := make(chan error, 1)
+
+	select {
+	case w.flushes <- result:
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *Writer) Close(ctx context.Context) error {
+	select {
+	case <-w.stop:
+	default:
+		close(w.stop)
+	}
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		w.l.Error("writer did not finish flushing before deadline, dropping rows", "table", w.table, "rows", w.Pending())
+		return ctx.Err()
+	}
+}
+
+// Pending reports how many rows have been written but not yet stored.
+func (w *Writer) Pending() int {
+	return int(w.buffered.Load()) + len(w.rows)
+}
+
+func (w *Writer) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.conf.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case m := <-w.rows:
+			w.add(m)
+			if len(w.buffer) >= w.conf.BatchSize && time.Now().After(w.retryAt) {
+				w.flush()
+			}
+
+		case <-ticker.C:
+			if time.Now().After(w.retryAt) {
+				w.flush()
+			}
+
+		case result := <-w.flushes:
+			w.drain()
+			result <- w.flush()
+
+		case <-w.stop:
+			w.drain()
+			if err := w.flush(); err != nil {
+				metrics.RowsWritten.WithLabelValues(string(w.table), "dropped").Add(float64(len(w.buffer)))
+				w.l.Error("dropped buffered rows on shutdown", "table", w.table, "rows", len(w.buffer), "error", err)
+			}
+			return
+		}
+	}
+}
+
+func (w *Writer) add(m models.Mappable) {
+	w.buffer = append(w.buffer, m)
+
+	if over := len(w.buffer) - w.conf.MaxBuffered; over > 0 {
+		w.buffer = w.buffer[over:]
+		metrics.RowsWritten.WithLabelValues(string(w.table), "dropped").Add(float64(over))
+		w.l.Warn("writer buffer full, dropped oldest rows", "table", w.table, "dropped", over)
+	}
+
+	w.setBuffered()
+}
+
+func (w *Writer) setBuffered() {
+	w.buffered.Store(int64(len(w.buffer)))
+	metrics.RowsBuffered.WithLabelValues(string(w.table)).Set(float64(len(w.buffer)))
+}
+
+func (w *Writer) drain() {
+	for {
+		select {
+		case m := <-w.rows:
+			w.add(m)
+		default:
+			return
+		}
+	}
+}
+
+func (w *Writer) flush() error {
+	ctx, cancel := context.WithTimeout(context.Background(), w.conf.FlushTimeout)
+	defer cancel()
+
+	for len(w.buffer) > 0 {
+		n := min(len(w.buffer), w.conf.BatchSize)
+
+		if err := w.q.CreateMany(ctx, w.buffer[:n]); err != nil {
+			w.failures++
+			if permanent(err) || w.failures > maxBatchRetries {
+				w.buffer = w.buffer[n:]
+				w.failures = 0
+				w.retryAt = time.Time{}
+
+				metrics.RowsWritten.WithLabelValues(string(w.table), "failed").Add(float64(n))
+				w.setBuffered()
+				w.l.Error("error writing batch, dropped it", "table", w.table, "rows", n, "buffered", len(w.buffer), "error", err)
+				continue
+			}
+
+			backoff := min(w.conf.FlushInterval<<min(w.failures-1, 10), maxRetryBackoff)
+			w.retryAt = time.Now().Add(backoff)
+
+			metrics.RowsWritten.WithLabelValues(string(w.table), "retried").Add(float64(n))
+			w.l.Warn("error writing batch, will retry", "table", w.table, "rows", n, "buffered", len(w.buffer), "retry_in", backoff, "error", err)
+			return err
+		}
+
+		w.buffer = w.buffer[n:]
+		w.failures = 0
+		w.retryAt = time.Time{}
+
+		metrics.RowsWritten.WithLabelValues(string(w.table), "ok").Add(float64(n))
+		w.setBuffered()
+	}
+
+	w.buffer = nil
+	return nil
+}
+
+// permanent reports whether retrying a batch can't succeed: Postgres data
+// exceptions, constraint violations and schema errors fail the same way every
+// time.
+func permanent(err error) bool {
+	var pe *pq.Error
+	if !errors.As(err, &pe) {
+		return false
+	}
+
+	switch pe.Code.Class() {
+	case "22", "23", "42":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,216 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/glotchimo/recast/internal/models"
+	"github.com/lib/pq"
+)
+
+// batchQuerier fails CreateMany with each of errs in turn, then records the
+// ids of every batch it accepts.
+type batchQuerier struct {
+	mu      sync.Mutex
+	errs    []error
+	block   bool
+	batches [][]string
+}
+
+func (q *batchQuerier) CreateMany(ctx context.Context, ms []models.Mappable) error {
+	q.mu.Lock()
+	block := q.block
+	q.mu.Unlock()
+	if block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.errs) > 0 {
+		err := q.errs[0]
+		q.errs = q.errs[1:]
+		return err
+	}
+
+	var ids []string
+	for _, m := range ms {
+		ids = append(ids, m.Map()["id"].(string))
+	}
+	q.batches = append(q.batches, ids)
+	return nil
+}
+
+func (q *batchQuerier) stored() [][]string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return slices.Clone(q.batches)
+}
+
+func (q *batchQuerier) Create(ctx context.Context, m models.Mappable) error { return nil }
+func (q *batchQuerier) Update(ctx context.Context, table models.Table, where sq.Eq, updates map[string]any) error {
+	return nil
+}
+func (q *batchQuerier) Delete(ctx context.Context, table models.Table, where sq.Eq) error { return nil }
+func (q *batchQuerier) Count(ctx context.Context, table models.Table, where sq.Eq) (int, error) {
+	return 0, nil
+}
+
+func newTestWriter(t *testing.T, q Querier, batchSize int) *Writer {
+	t.Helper()
+
+	w := NewWriter(slog.New(slog.NewTextHandler(io.Discard, nil)), q, testTable, WriterConfig{
+		BatchSize:     batchSize,
+		FlushInterval: time.Hour,
+		FlushTimeout:  time.Second,
+	})
+	t.Cleanup(func() { w.Close(context.Background()) })
+
+	return w
+}
+
+func write(w *Writer, ids ...string) {
+	for _, id := range ids {
+		w.Write(thing{"id": id})
+	}
+}
+
+func TestWriterBatches(t *testing.T) {
+	q := &batchQuerier{}
+	w := newTestWriter(t, q, 2)
+
+	write(w, "1", "2", "3", "4", "5")
+	if err := w.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	want := [][]string{{"1", "2"}, {"3", "4"}, {"5"}}
+	if got := q.stored(); !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("got batches %q, want %q", got, want)
+	}
+	if n := w.Pending(); n != 0 {
+		t.Errorf("got %d pending rows, want 0", n)
+	}
+}
+
+func TestWriterFailures(t *testing.T) {
+	transient := errors.New("connection refused")
+	permanent := &pq.Error{Code: "23505", Message: "duplicate key value violates unique constraint"}
+
+	retries := make([]error, maxBatchRetries+1)
+	for i := range retries {
+		retries[i] = transient
+	}
+
+	tests := []struct {
+		name    string
+		errs    []error
+		flushes int
+		want    [][]string
+		pending int
+	}{
+		{
+			name:    "retried",
+			errs:    []error{transient},
+			flushes: 2,
+			want:    [][]string{{"1", "2"}, {"3"}},
+		},
+		{
+			name:    "still retrying",
+			errs:    retries[:maxBatchRetries],
+			flushes: maxBatchRetries,
+			pending: 3,
+		},
+		{
+			name:    "retries exhausted",
+			errs:    retries,
+			flushes: maxBatchRetries + 1,
+			want:    [][]string{{"3"}},
+		},
+		{
+			name:    "permanent",
+			errs:    []error{permanent},
+			flushes: 1,
+			want:    [][]string{{"3"}},
+		},
+		{
+			name:    "wrapped permanent",
+			errs:    []error{errors.Join(errors.New("writing batch"), permanent)},
+			flushes: 1,
+			want:    [][]string{{"3"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &batchQuerier{errs: tt.errs}
+			w := newTestWriter(t, q, 2)
+
+			write(w, "1", "2", "3")
+
+			var err error
+			for range tt.flushes {
+				err = w.Flush(context.Background())
+			}
+			if (err != nil) != (tt.pending > 0) {
+				t.Errorf("last Flush() error = %v, want an error only while rows are pending", err)
+			}
+
+			if got := q.stored(); !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("got batches %q, want %q", got, tt.want)
+			}
+			if n := w.Pending(); n != tt.pending {
+				t.Errorf("got %d pending rows, want %d", n, tt.pending)
+			}
+		})
+	}
+}
+
+func TestWriterClose(t *testing.T) {
+	t.Run("flushes", func(t *testing.T) {
+		q := &batchQuerier{}
+		w := newTestWriter(t, q, 10)
+
+		write(w, "1", "2")
+		if err := w.Close(context.Background()); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+		if got, want := q.stored(), [][]string{{"1", "2"}}; !slices.EqualFunc(got, want, slices.Equal) {
+			t.Errorf("got batches %q, want %q", got, want)
+		}
+
+		// Rows written after closing are dropped rather than queued.
+		write(w, "3")
+		if n := w.Pending(); n != 0 {
+			t.Errorf("got %d pending rows after closing, want 0", n)
+		}
+		if err := w.Flush(context.Background()); err != nil {
+			t.Errorf("Flush() after Close() error = %v", err)
+		}
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		q := &batchQuerier{block: true}
+		w := newTestWriter(t, q, 10)
+
+		write(w, "1", "2")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if err := w.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Close() error = %v, want context.DeadlineExceeded", err)
+		}
+		if n := w.Pending(); n != 2 {
+			t.Errorf("got %d pending rows at the deadline, want 2", n)
+		}
+	})
+}
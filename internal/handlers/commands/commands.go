@@ -0,0 +1,370 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	dg "github.com/bwmarrin/discordgo"
+	db "github.com/glotchimo/recast/internal/database"
+	"github.com/glotchimo/recast/internal/handlers"
+	md "github.com/glotchimo/recast/internal/models"
+	rp "github.com/glotchimo/recast/internal/response"
+	"github.com/glotchimo/recast/internal/utils"
+)
+
+var aliasPattern = regexp.MustCompile(`^[-_\p{Ll}\p{N}]{1,32}$`)
+
+type Commands struct{}
+
+func (c *Commands) Metadata() dg.ApplicationCommand {
+	command := &dg.ApplicationCommandOption{
+		Type:         dg.ApplicationCommandOptionString,
+		Name:         "command",
+		Description:  "The command to configure",
+		Required:     true,
+		Autocomplete: true,
+	}
+
+	return dg.ApplicationCommand{
+		Name:        "commands",
+		Description: "Configure which commands are available in this server",
+		Options: []*dg.ApplicationCommandOption{
+			{
+				Type:        dg.ApplicationCommandOptionSubCommand,
+				Name:        "list",
+				Description: "List commands and their settings",
+			},
+			{
+				Type:        dg.ApplicationCommandOptionSubCommand,
+				Name:        "enable",
+				Description: "Enable a command",
+				Options:     []*dg.ApplicationCommandOption{command},
+			},
+			{
+				Type:        dg.ApplicationCommandOptionSubCommand,
+				Name:        "disable",
+				Description: "Disable a command",
+				Options:     []*dg.ApplicationCommandOption{command},
+			},
+			{
+				Type:        dg.ApplicationCommandOptionSubCommand,
+				Name:        "rename",
+				Description: "Register a command under a different name",
+				Options: []*dg.ApplicationCommandOption{
+					command,
+					{
+						Type:        dg.ApplicationCommandOptionString,
+						Name:        "name",
+						Description: "The new name, or leave empty to enter it in a form",
+					},
+				},
+			},
+			{
+				Type:        dg.ApplicationCommandOptionSubCommand,
+				Name:        "reset",
+				Description: "Restore a command's default settings",
+				Options:     []*dg.ApplicationCommandOption{command},
+			},
+		},
+	}
+}
+
+func (c *Commands) Requirements() handlers.Requirements {
+	return handlers.Requirements{Permissions: dg.PermissionManageGuild}
+}
+
+func (c *Commands) Subcommands() map[string]handlers.HandlerFunc {
+	return map[string]handlers.HandlerFunc{
+		"list":    c.list,
+		"enable":  c.enable,
+		"disable": c.disable,
+		"rename":  c.rename,
+		"reset":   c.reset,
+	}
+}
+
+func (c *Commands) Handle(ctx context.Context, dep handlers.Dependencies) error {
+	return c.list(ctx, dep)
+}
+
+func (c *Commands) Autocomplete(ctx context.Context, dep handlers.Dependencies, focused *dg.ApplicationCommandInteractionDataOption) ([]*dg.ApplicationCommandOptionChoice, error) {
+	prefix := strings.ToLower(focused.StringValue())
+
+	var choices []*dg.ApplicationCommandOptionChoice
+	for _, cmd := range dep.Registry.Commands() {
+		if strings.HasPrefix(strings.ToLower(cmd.Name), prefix) {
+			choices = append(choices, &dg.ApplicationCommandOptionChoice{Name: cmd.Name, Value: cmd.Name})
+		}
+	}
+
+	return choices, nil
+}
+
+func (c *Commands) list(ctx context.Context, dep handlers.Dependencies) error {
+	if err := dep.Responder.Defer(dep.Interaction, true); err != nil {
+		return err
+	}
+
+	var lines []string
+	for _, cmd := range dep.Registry.Commands() {
+		cs := dep.Guild.Settings.Commands[cmd.Name]
+
+		line := fmt.Sprintf("`%s`", cmd.Name)
+		if cs.Alias != "" {
+			line += fmt.Sprintf(" as `%s`", cs.Alias)
+		}
+		if cs.Disabled {
+			line += " (disabled)"
+		}
+		lines = append(lines, line)
+	}
+
+	embed := dg.MessageEmbed{
+		Title:       "Commands",
+		Description: strings.Join(lines, "\n"),
+	}
+
+	return dep.Responder.Send(dep.Interaction, rp.MessageOptions{Embeds: []*dg.MessageEmbed{&embed}, Ephemeral: true})
+}
+
+func (c *Commands) enable(ctx context.Context, dep handlers.Dependencies) error {
+	return c.update(ctx, dep, c.target(dep), func(cs *md.CommandSettings) string {
+		cs.Disabled = false
+		return "enabled"
+	})
+}
+
+func (c *Commands) disable(ctx context.Context, dep handlers.Dependencies) error {
+	if c.target(dep) == c.Metadata().Name {
+		return utils.Failure{
+			Type:    utils.ErrBadInput,
+			Message: fmt.Sprintf("`/%s` can't be disabled", c.Metadata().Name),
+		}
+	}
+
+	return c.update(ctx, dep, c.target(dep), func(cs *md.CommandSettings) string {
+		cs.Disabled = true
+		return "disabled"
+	})
+}
+
+type renameForm struct {
+	Name string `modal:"name,required"`
+}
+
+func (c *Commands) rename(ctx context.Context, dep handlers.Dependencies) error {
+	name := c.target(dep)
+
+	opt, ok := (*dep.Options)["name"]
+	if ok {
+		return c.renameTo(ctx, dep, name, strings.TrimSpace(opt.StringValue()))
+	}
+
+	if err := c.check(dep, name); err != nil {
+		return err
+	}
+
+	current := dep.Guild.Settings.Commands[name].Alias
+	if current == "" {
+		current = name
+	}
+
+	return dep.Responder.Modal(dep.Interaction, handlers.CustomID{Handler: c.Metadata().Name, Action: "rename", State: name}.String(), fmt.Sprintf("Rename %s", name), dg.TextInput{
+		CustomID:  "name",
+		Label:     "New name",
+		Style:     dg.TextInputShort,
+		Value:     current,
+		Required:  true,
+		MinLength: 1,
+		MaxLength: 32,
+	})
+}
+
+func (c *Commands) HandleModal(ctx context.Context, dep handlers.Dependencies) error {
+	if dep.CustomID.Action != "rename" {
+		return utils.Failure{
+			Type:    utils.ErrBadInput,
+			Message: "Unrecognized form",
+			Data:    map[string]any{"custom_id": dep.CustomID.String()},
+		}
+	}
+
+	var form renameForm
+	if err := utils.DecodeModal(dep.Interaction.ModalSubmitData(), &form); err != nil {
+		return err
+	}
+
+	return c.renameTo(ctx, dep, dep.CustomID.State, strings.TrimSpace(form.Name))
+}
+
+func (c *Commands) renameTo(ctx context.Context, dep handlers.Dependencies, name, alias string) error {
+	var kind dg.ApplicationCommandType
+	for _, cmd := range dep.Registry.Commands() {
+		if cmd.Name == name {
+			kind = cmd.Type
+		}
+		if cmd.Name == alias && cmd.Name != name {
+			return utils.Failure{
+				Type:    utils.ErrBadInput,
+				Message: fmt.Sprintf("`%s` is already the name of another command", alias),
+			}
+		}
+	}
+
+	if kind == dg.UserApplicationCommand || kind == dg.MessageApplicationCommand {
+		if len(alias) == 0 || len(alias) > 32 {
+			return utils.Failure{Type: utils.ErrBadInput, Message: "Names must be between 1 and 32 characters"}
+		}
+	} else if !aliasPattern.MatchString(alias) {
+		return utils.Failure{Type: utils.ErrBadInput, Message: "Names must be 1-32 lowercase letters, numbers, dashes or underscores"}
+	}
+
+	for other, cs := range dep.Guild.Settings.Commands {
+		if other != name && cs.Alias == alias {
+			return utils.Failure{
+				Type:    utils.ErrBadInput,
+				Message: fmt.Sprintf("`%s` is already used by `%s`", alias, other),
+			}
+		}
+	}
+
+	return c.update(ctx, dep, name, func(cs *md.CommandSettings) string {
+		if alias == name {
+			cs.Alias = ""
+		} else {
+			cs.Alias = alias
+		}
+		return fmt.Sprintf("renamed to `%s`", alias)
+	})
+}
+
+// reset asks for confirmation first, since it discards both the alias and
+// whether the command is disabled.
+func (c *Commands) reset(ctx context.Context, dep handlers.Dependencies) error {
+	name := c.target(dep)
+	if err := c.check(dep, name); err != nil {
+		return err
+	}
+
+	if err := dep.Responder.Defer(dep.Interaction, true); err != nil {
+		return err
+	}
+
+	embed := dg.MessageEmbed{
+		Title:       "Reset Command",
+		Description: fmt.Sprintf("Reset `%s` to its default name and enable it?", name),
+	}
+	buttons := dg.ActionsRow{Components: []dg.MessageComponent{
+		dg.Button{
+			Label:    "Reset",
+			Style:    dg.DangerButton,
+			CustomID: handlers.CustomID{Handler: c.Metadata().Name, Action: "reset", State: name}.String(),
+		},
+		dg.Button{
+			Label:    "Cancel",
+			Style:    dg.SecondaryButton,
+			CustomID: handlers.CustomID{Handler: c.Metadata().Name, Action: "cancel"}.String(),
+		},
+	}}
+
+	return dep.Responder.Send(dep.Interaction, rp.MessageOptions{
+		Embeds:     []*dg.MessageEmbed{&embed},
+		Components: []dg.MessageComponent{buttons},
+		Ephemeral:  true,
+	})
+}
+
+func (c *Commands) HandleComponent(ctx context.Context, dep handlers.Dependencies) error {
+	switch dep.CustomID.Action {
+	case "reset":
+		return c.update(ctx, dep, dep.CustomID.State, func(cs *md.CommandSettings) string {
+			*cs = md.CommandSettings{}
+			return "reset"
+		})
+	case "cancel":
+		embed := dg.MessageEmbed{
+			Title:       "Reset Cancelled",
+			Description: "Nothing was changed.",
+		}
+		return dep.Responder.Update(dep.Interaction, rp.MessageOptions{Embeds: []*dg.MessageEmbed{&embed}, Components: []dg.MessageComponent{}})
+	}
+
+	return utils.Failure{
+		Type:    utils.ErrBadInput,
+		Message: "Unrecognized action",
+		Data:    map[string]any{"custom_id": dep.CustomID.String()},
+	}
+}
+
+func (c *Commands) target(dep handlers.Dependencies) string {
+	return (*dep.Options)["command"].StringValue()
+}
+
+func (c *Commands) check(dep handlers.Dependencies, name string) error {
+	known := false
+	for _, cmd := range dep.Registry.Commands() {
+		if cmd.Name == name {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return utils.Failure{
+			Type:    utils.ErrNotFound,
+			Message: fmt.Sprintf("There's no command named `%s`", name),
+		}
+	}
+	if !dep.Registry.Overridable(dep.Guild.ID, name) {
+		return utils.Failure{
+			Type:    utils.ErrNotAllowed,
+			Message: fmt.Sprintf("`%s` is registered globally, so it can't be configured per server", name),
+		}
+	}
+
+	return nil
+}
+
+// update applies a change to a command's settings. Button presses edit the
+// message they came from; everything else gets an ephemeral followup.
+func (c *Commands) update(ctx context.Context, dep handlers.Dependencies, name string, apply func(*md.CommandSettings) string) error {
+	if err := c.check(dep, name); err != nil {
+		return err
+	}
+
+	component := dep.Interaction.Type == dg.InteractionMessageComponent
+	if component {
+		if err := dep.Responder.DeferUpdate(dep.Interaction); err != nil {
+			return err
+		}
+	} else if err := dep.Responder.Defer(dep.Interaction, true); err != nil {
+		return err
+	}
+
+	cs := dep.Guild.Settings.Commands[name]
+	result := apply(&cs)
+
+	if err := dep.Database.Update(ctx, md.TableGuilds, sq.Eq{"id": dep.Guild.ID}, map[string]any{
+		"settings": db.SetJSON("settings", cs, "commands", name),
+	}); err != nil {
+		return err
+	}
+
+	if err := dep.Registry.Reload(dep.Guild.ID); err != nil {
+		return err
+	}
+
+	dep.Relay(fmt.Sprintf("`%s` was %s by %s", name, result, utils.FormatUserMention(md.Interaction{Interaction: dep.Interaction.Interaction}.UserID())))
+
+	embed := dg.MessageEmbed{
+		Title:       "Commands Updated",
+		Description: fmt.Sprintf("`%s` was %s.", name, result),
+	}
+
+	if component {
+		return dep.Responder.EditOriginal(dep.Interaction, rp.MessageOptions{Embeds: []*dg.MessageEmbed{&embed}, Components: []dg.MessageComponent{}})
+	}
+	return dep.Responder.Send(dep.Interaction, rp.MessageOptions{Embeds: []*dg.MessageEmbed{&embed}, Ephemeral: true})
+}
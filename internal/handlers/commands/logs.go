@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	dg "github.com/bwmarrin/discordgo"
+	db "github.com/glotchimo/recast/internal/database"
+	"github.com/glotchimo/recast/internal/handlers"
+	md "github.com/glotchimo/recast/internal/models"
+	rp "github.com/glotchimo/recast/internal/response"
+	"github.com/glotchimo/recast/internal/utils"
+)
+
+type Logs struct{}
+
+func (l *Logs) Metadata() dg.ApplicationCommand {
+	return dg.ApplicationCommand{
+		Name:        "logs",
+		Description: "Configure where the bot posts its audit log",
+		Options: []*dg.ApplicationCommandOption{
+			{
+				Type:        dg.ApplicationCommandOptionSubCommand,
+				Name:        "set-channel",
+				Description: "Post command usage, failures and configuration changes to a channel",
+				Options: []*dg.ApplicationCommandOption{
+					{
+						Type:         dg.ApplicationCommandOptionChannel,
+						Name:         "channel",
+						Description:  "The channel to post to",
+						Required:     true,
+						ChannelTypes: []dg.ChannelType{dg.ChannelTypeGuildText, dg.ChannelTypeGuildNews},
+					},
+				},
+			},
+			{
+				Type:        dg.ApplicationCommandOptionSubCommand,
+				Name:        "clear",
+				Description: "Stop posting the audit log",
+			},
+		},
+	}
+}
+
+func (l *Logs) Requirements() handlers.Requirements {
+	return handlers.Requirements{Permissions: dg.PermissionManageGuild}
+}
+
+func (l *Logs) Subcommands() map[string]handlers.HandlerFunc {
+	return map[string]handlers.HandlerFunc{
+		"set-channel": l.setChannel,
+		"clear":       l.clear,
+	}
+}
+
+func (l *Logs) Handle(ctx context.Context, dep handlers.Dependencies) error {
+	return utils.Failure{Type: utils.ErrBadInput, Message: "Choose a subcommand"}
+}
+
+func (l *Logs) setChannel(ctx context.Context, dep handlers.Dependencies) error {
+	channelID, _ := (*dep.Options)["channel"].Value.(string)
+
+	if err := l.update(ctx, dep, channelID); err != nil {
+		return err
+	}
+
+	dep.Relay(fmt.Sprintf("%s set the log channel to <#%s>", utils.FormatUserMention(md.Interaction{Interaction: dep.Interaction.Interaction}.UserID()), channelID))
+
+	embed := dg.MessageEmbed{
+		Title:       "Log Channel Updated",
+		Description: fmt.Sprintf("Command usage, failures and configuration changes will be posted to <#%s>.", channelID),
+	}
+
+	return dep.Responder.Send(dep.Interaction, rp.MessageOptions{Embeds: []*dg.MessageEmbed{&embed}, Ephemeral: true})
+}
+
+func (l *Logs) clear(ctx context.Context, dep handlers.Dependencies) error {
+	if err := l.update(ctx, dep, ""); err != nil {
+		return err
+	}
+
+	embed := dg.MessageEmbed{
+		Title:       "Log Channel Cleared",
+		Description: "The audit log will no longer be posted.",
+	}
+
+	return dep.Responder.Send(dep.Interaction, rp.MessageOptions{Embeds: []*dg.MessageEmbed{&embed}, Ephemeral: true})
+}
+
+func (l *Logs) update(ctx context.Context, dep handlers.Dependencies, channelID string) error {
+	if err := dep.Responder.Defer(dep.Interaction, true); err != nil {
+		return err
+	}
+
+	return dep.Database.Update(ctx, md.TableGuilds, sq.Eq{"id": dep.Guild.ID}, map[string]any{
+		"settings": db.SetJSON("settings", channelID, "log_channel_id"),
+	})
+}
@@ -0,0 +1,118 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	dg "github.com/bwmarrin/discordgo"
+	db "github.com/glotchimo/recast/internal/database"
+	"github.com/glotchimo/recast/internal/handlers"
+	md "github.com/glotchimo/recast/internal/models"
+	rp "github.com/glotchimo/recast/internal/response"
+	"github.com/glotchimo/recast/internal/utils"
+)
+
+const maxRetentionDays = 3650
+
+type Retention struct{}
+
+func (r *Retention) Metadata() dg.ApplicationCommand {
+	minDays := 0.0
+
+	return dg.ApplicationCommand{
+		Name:        "retention",
+		Description: "Configure how long the bot keeps this server's interaction history",
+		Options: []*dg.ApplicationCommandOption{
+			{
+				Type:        dg.ApplicationCommandOptionSubCommand,
+				Name:        "set",
+				Description: "Keep interactions and voice events for a number of days",
+				Options: []*dg.ApplicationCommandOption{
+					{
+						Type:        dg.ApplicationCommandOptionInteger,
+						Name:        "days",
+						Description: "Days to keep history, 0 to keep it forever",
+						Required:    true,
+						MinValue:    &minDays,
+						MaxValue:    maxRetentionDays,
+					},
+				},
+			},
+			{
+				Type:        dg.ApplicationCommandOptionSubCommand,
+				Name:        "reset",
+				Description: "Go back to the bot's default retention",
+			},
+		},
+	}
+}
+
+func (r *Retention) Requirements() handlers.Requirements {
+	return handlers.Requirements{Permissions: dg.PermissionManageGuild}
+}
+
+func (r *Retention) Subcommands() map[string]handlers.HandlerFunc {
+	return map[string]handlers.HandlerFunc{
+		"set":   r.set,
+		"reset": r.reset,
+	}
+}
+
+func (r *Retention) Handle(ctx context.Context, dep handlers.Dependencies) error {
+	return utils.Failure{Type: utils.ErrBadInput, Message: "Choose a subcommand"}
+}
+
+func (r *Retention) set(ctx context.Context, dep handlers.Dependencies) error {
+	days := int((*dep.Options)["days"].IntValue())
+	if days < 0 || days > maxRetentionDays {
+		return utils.Failure{Type: utils.ErrBadInput, Message: fmt.Sprintf("Days must be between 0 and %d", maxRetentionDays)}
+	}
+
+	if err := r.update(ctx, dep, days); err != nil {
+		return err
+	}
+
+	period := fmt.Sprintf("%d days", days)
+	if days == 1 {
+		period = "1 day"
+	}
+
+	description := fmt.Sprintf("Interactions and voice events older than %s will be deleted.", period)
+	if days == 0 {
+		description = "Interactions and voice events will be kept forever."
+	}
+	dep.Relay(fmt.Sprintf("%s set retention to %s", utils.FormatUserMention(md.Interaction{Interaction: dep.Interaction.Interaction}.UserID()), period))
+
+	embed := dg.MessageEmbed{
+		Title:       "Retention Updated",
+		Description: description,
+	}
+
+	return dep.Responder.Send(dep.Interaction, rp.MessageOptions{Embeds: []*dg.MessageEmbed{&embed}, Ephemeral: true})
+}
+
+func (r *Retention) reset(ctx context.Context, dep handlers.Dependencies) error {
+	if err := r.update(ctx, dep, nil); err != nil {
+		return err
+	}
+
+	dep.Relay(fmt.Sprintf("%s reset retention to the default", utils.FormatUserMention(md.Interaction{Interaction: dep.Interaction.Interaction}.UserID())))
+
+	embed := dg.MessageEmbed{
+		Title:       "Retention Reset",
+		Description: "This server now follows the bot's default retention.",
+	}
+
+	return dep.Responder.Send(dep.Interaction, rp.MessageOptions{Embeds: []*dg.MessageEmbed{&embed}, Ephemeral: true})
+}
+
+func (r *Retention) update(ctx context.Context, dep handlers.Dependencies, days any) error {
+	if err := dep.Responder.Defer(dep.Interaction, true); err != nil {
+		return err
+	}
+
+	return dep.Database.Update(ctx, md.TableGuilds, sq.Eq{"id": dep.Guild.ID}, map[string]any{
+		"settings": db.SetJSON("settings", days, "retention_days"),
+	})
+}
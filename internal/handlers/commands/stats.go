@@ -0,0 +1,173 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/glotchimo/recast/internal/handlers"
+	md "github.com/glotchimo/recast/internal/models"
+	rp "github.com/glotchimo/recast/internal/response"
+	"github.com/glotchimo/recast/internal/utils"
+	"github.com/graxinc/errutil"
+)
+
+const (
+	statsCooldown     = time.Minute
+	statsBurst        = 3
+	statsDefaultDays  = 30
+	statsTopCommands  = 10
+	statsBarWidth     = 20
+	statsWeeklyCutoff = 31
+)
+
+type Stats struct{}
+
+func (s *Stats) Metadata() dg.ApplicationCommand {
+	return dg.ApplicationCommand{
+		Name:        "stats",
+		Description: "Show how the bot is used in this server",
+		Options: []*dg.ApplicationCommandOption{
+			{
+				Type:        dg.ApplicationCommandOptionInteger,
+				Name:        "days",
+				Description: "How far back to look (default 30)",
+				Choices: []*dg.ApplicationCommandOptionChoice{
+					{Name: "7 days", Value: 7},
+					{Name: "30 days", Value: 30},
+					{Name: "90 days", Value: 90},
+				},
+			},
+		},
+	}
+}
+
+func (s *Stats) Requirements() handlers.Requirements {
+	return handlers.Requirements{Permissions: dg.PermissionManageGuild}
+}
+
+// Cooldown is per guild since every admin reads the same aggregates.
+func (s *Stats) Cooldown() handlers.Cooldown {
+	return handlers.Cooldown{Scope: handlers.CooldownGuild, Duration: statsCooldown, Burst: statsBurst}
+}
+
+func (s *Stats) Handle(ctx context.Context, dep handlers.Dependencies) error {
+	if err := dep.Responder.Defer(dep.Interaction, true); err != nil {
+		return err
+	}
+
+	days := statsDefaultDays
+	if opt, ok := (*dep.Options)["days"]; ok {
+		days = int(opt.IntValue())
+	}
+
+	guildID := dep.Interaction.GuildID
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1-days)
+
+	commands, err := dep.Database.CommandUsage(ctx, guildID, since)
+	if err != nil {
+		return errutil.With(err)
+	}
+	daily, err := dep.Database.DailyUsage(ctx, guildID, since)
+	if err != nil {
+		return errutil.With(err)
+	}
+	users, err := dep.Database.UniqueUsers(ctx, guildID, since)
+	if err != nil {
+		return errutil.With(err)
+	}
+
+	var total md.CommandUsage
+	for _, u := range commands {
+		total.Uses += u.Uses
+		total.Failures += u.Failures
+		total.Errors += u.Errors
+	}
+
+	var interactions int
+	for _, d := range daily {
+		interactions += d.Interactions
+	}
+
+	summary := dg.MessageEmbed{
+		Title:       fmt.Sprintf("Usage over the last %d days", days),
+		Description: fmt.Sprintf("Since %s", utils.FormatTimestamp(since, utils.TimestampLongDate)),
+		Fields: []*dg.MessageEmbedField{
+			{Name: "Interactions", Value: fmt.Sprint(interactions), Inline: true},
+			{Name: "Unique users", Value: fmt.Sprint(users), Inline: true},
+			{Name: "Commands used", Value: fmt.Sprint(len(commands)), Inline: true},
+			{Name: "Failure rate", Value: utils.FormatPercent(total.Failures, total.Uses), Inline: true},
+			{Name: "Error rate", Value: utils.FormatPercent(total.Errors, total.Uses), Inline: true},
+		},
+	}
+
+	if interactions == 0 {
+		summary.Description += "\n\nNo usage recorded yet. Statistics are updated periodically."
+		return dep.Responder.Send(dep.Interaction, rp.MessageOptions{Embeds: []*dg.MessageEmbed{&summary}, Ephemeral: true})
+	}
+
+	embeds := []*dg.MessageEmbed{&summary, s.activity(since, days, daily), s.top(commands)}
+	return dep.Responder.Send(dep.Interaction, rp.MessageOptions{Embeds: embeds, Ephemeral: true})
+}
+
+func (s *Stats) activity(since time.Time, days int, daily []md.DailyUsage) *dg.MessageEmbed {
+	step, title := 1, "Daily activity"
+	if days > statsWeeklyCutoff {
+		step, title = 7, "Weekly activity"
+	}
+
+	counts := make([]int, (days+step-1)/step)
+	for _, d := range daily {
+		if i := int(d.Day.Sub(since)/(24*time.Hour)) / step; i >= 0 && i < len(counts) {
+			counts[i] += d.Interactions
+		}
+	}
+
+	peak := 0
+	for _, n := range counts {
+		peak = max(peak, n)
+	}
+
+	var lines []string
+	for i, n := range counts {
+		day := since.AddDate(0, 0, i*step)
+		lines = append(lines, fmt.Sprintf("%s %s %d", day.Format("Jan 02"), bar(n, peak), n))
+	}
+
+	return &dg.MessageEmbed{
+		Title:       title,
+		Description: "```\n" + strings.Join(lines, "\n") + "\n```",
+	}
+}
+
+func (s *Stats) top(commands []md.CommandUsage) *dg.MessageEmbed {
+	commands = commands[:min(len(commands), statsTopCommands)]
+
+	width, peak := 0, 0
+	for _, u := range commands {
+		width = max(width, len(u.Command))
+		peak = max(peak, u.Uses)
+	}
+
+	var lines []string
+	for _, u := range commands {
+		line := fmt.Sprintf("%-*s %s %d", width, u.Command, bar(u.Uses, peak), u.Uses)
+		if u.Errors > 0 || u.Failures > 0 {
+			line += fmt.Sprintf(" (%s failed, %s errored)", utils.FormatPercent(u.Failures, u.Uses), utils.FormatPercent(u.Errors, u.Uses))
+		}
+		lines = append(lines, line)
+	}
+
+	return &dg.MessageEmbed{
+		Title:       "Top commands",
+		Description: "```\n" + strings.Join(lines, "\n") + "\n```",
+	}
+}
+
+func bar(n, peak int) string {
+	b := utils.FormatBar(n, peak, statsBarWidth)
+	return b + strings.Repeat(" ", statsBarWidth-utf8.RuneCountInString(b))
+}
@@ -0,0 +1,169 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/glotchimo/recast/internal/handlers"
+	rp "github.com/glotchimo/recast/internal/response"
+	"github.com/glotchimo/recast/internal/utils"
+	"github.com/graxinc/errutil"
+)
+
+const (
+	voiceDefaultDays      = 30
+	voiceLeaderboardLimit = 10
+	voiceHistoryLimit     = 10
+)
+
+type Voice struct{}
+
+func (v *Voice) Metadata() dg.ApplicationCommand {
+	return dg.ApplicationCommand{
+		Name:        "voice",
+		Description: "See how members spend time in voice channels",
+		Options: []*dg.ApplicationCommandOption{
+			{
+				Type:        dg.ApplicationCommandOptionSubCommand,
+				Name:        "leaderboard",
+				Description: "Members who spent the most time in voice",
+				Options: []*dg.ApplicationCommandOption{
+					{
+						Type:        dg.ApplicationCommandOptionInteger,
+						Name:        "days",
+						Description: "How far back to look (default 30)",
+						Choices: []*dg.ApplicationCommandOptionChoice{
+							{Name: "7 days", Value: 7},
+							{Name: "30 days", Value: 30},
+							{Name: "90 days", Value: 90},
+						},
+					},
+				},
+			},
+			{
+				Type:        dg.ApplicationCommandOptionSubCommand,
+				Name:        "history",
+				Description: "A member's recent voice sessions",
+				Options: []*dg.ApplicationCommandOption{
+					{
+						Type:        dg.ApplicationCommandOptionUser,
+						Name:        "user",
+						Description: "The member to look up",
+						Required:    true,
+					},
+				},
+			},
+		},
+	}
+}
+
+func (v *Voice) Subcommands() map[string]handlers.HandlerFunc {
+	return map[string]handlers.HandlerFunc{
+		"leaderboard": v.leaderboard,
+		"history":     v.history,
+	}
+}
+
+func (v *Voice) Handle(ctx context.Context, dep handlers.Dependencies) error {
+	return v.leaderboard(ctx, dep)
+}
+
+func (v *Voice) leaderboard(ctx context.Context, dep handlers.Dependencies) error {
+	if err := dep.Responder.Defer(dep.Interaction, false); err != nil {
+		return err
+	}
+
+	days := voiceDefaultDays
+	if opt, ok := (*dep.Options)["days"]; ok {
+		days = int(opt.IntValue())
+	}
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1-days)
+
+	totals, err := dep.Database.VoiceLeaderboard(ctx, dep.Guild.ID, since, voiceLeaderboardLimit)
+	if err != nil {
+		return errutil.With(err)
+	}
+
+	var lines []string
+	for i, t := range totals {
+		lines = append(lines, fmt.Sprintf("%d. %s: %s (%s)", i+1, utils.FormatUserMention(t.UserID), utils.FormatDuration(t.Duration), sessionCount(t.Sessions)))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "Nobody has been in voice yet.")
+	}
+
+	embed := dg.MessageEmbed{
+		Title:       fmt.Sprintf("Voice leaderboard, last %d days", days),
+		Description: strings.Join(lines, "\n"),
+	}
+
+	return dep.Responder.Send(dep.Interaction, rp.MessageOptions{Embeds: []*dg.MessageEmbed{&embed}})
+}
+
+func (v *Voice) history(ctx context.Context, dep handlers.Dependencies) error {
+	userID, _ := (*dep.Options)["user"].Value.(string)
+	return voiceHistory(ctx, dep, userID)
+}
+
+// VoiceHistory shows the same history as /voice history from a member's
+// context menu.
+type VoiceHistory struct{}
+
+func (v *VoiceHistory) Metadata() dg.ApplicationCommand {
+	return dg.ApplicationCommand{
+		Name: "Voice History",
+		Type: dg.UserApplicationCommand,
+	}
+}
+
+func (v *VoiceHistory) Handle(ctx context.Context, dep handlers.Dependencies) error {
+	return voiceHistory(ctx, dep, dep.TargetUser.ID)
+}
+
+func voiceHistory(ctx context.Context, dep handlers.Dependencies, userID string) error {
+	if err := dep.Responder.Defer(dep.Interaction, true); err != nil {
+		return err
+	}
+
+	sessions, err := dep.Database.VoiceHistory(ctx, dep.Guild.ID, userID, voiceHistoryLimit)
+	if err != nil {
+		return errutil.With(err)
+	}
+
+	now := time.Now()
+	var total time.Duration
+	var lines []string
+	for _, s := range sessions {
+		d := s.Duration(now)
+		total += d
+
+		line := fmt.Sprintf("<#%s> %s, %s", s.ChannelID, utils.FormatTimestamp(s.Created, utils.TimestampShortDateTime), utils.FormatDuration(d))
+		if s.Ended == nil {
+			line += " (ongoing)"
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		lines = append(lines, fmt.Sprintf("%s hasn't been in voice yet.", utils.FormatUserMention(userID)))
+	}
+
+	embed := dg.MessageEmbed{
+		Title:       "Voice history",
+		Description: fmt.Sprintf("Recent sessions for %s\n\n%s", utils.FormatUserMention(userID), strings.Join(lines, "\n")),
+	}
+	if len(sessions) > 0 {
+		embed.Footer = &dg.MessageEmbedFooter{Text: fmt.Sprintf("%s across %s", utils.FormatDuration(total), sessionCount(len(sessions)))}
+	}
+
+	return dep.Responder.Send(dep.Interaction, rp.MessageOptions{Embeds: []*dg.MessageEmbed{&embed}, Ephemeral: true})
+}
+
+func sessionCount(n int) string {
+	if n == 1 {
+		return "1 session"
+	}
+	return fmt.Sprintf("%d sessions", n)
+}
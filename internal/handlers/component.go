@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const customIDSeparator = ":"
+
+type CustomID struct {
+	Handler string
+	Action  string
+	State   string
+}
+
+func ParseCustomID(s string) (CustomID, error) {
+	parts := strings.SplitN(s, customIDSeparator, 3)
+	if parts[0] == "" {
+		return CustomID{}, fmt.Errorf("invalid custom ID %q", s)
+	}
+
+	id := CustomID{Handler: parts[0]}
+	if len(parts) > 1 {
+		id.Action = parts[1]
+	}
+	if len(parts) > 2 {
+		id.State = parts[2]
+	}
+
+	return id, nil
+}
+
+func (c CustomID) String() string {
+	parts := []string{c.Handler, c.Action, c.State}
+	for len(parts) > 1 && parts[len(parts)-1] == "" {
+		parts = parts[:len(parts)-1]
+	}
+	return strings.Join(parts, customIDSeparator)
+}
+
+type ComponentHandler interface {
+	HandleComponent(context.Context, Dependencies) error
+}
+
+type ModalHandler interface {
+	HandleModal(context.Context, Dependencies) error
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import "testing"
+
+func TestParseCustomID(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    CustomID
+		wantErr bool
+	}{
+		{in: "commands", want: CustomID{Handler: "commands"}},
+		{in: "commands:cancel", want: CustomID{Handler: "commands", Action: "cancel"}},
+		{in: "commands:reset:ping", want: CustomID{Handler: "commands", Action: "reset", State: "ping"}},
+		{in: "commands:reset:a:b:c", want: CustomID{Handler: "commands", Action: "reset", State: "a:b:c"}},
+		{in: "commands::state", want: CustomID{Handler: "commands", State: "state"}},
+		{in: "", wantErr: true},
+		{in: ":reset", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseCustomID(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseCustomID(%q) = %+v, want error", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCustomID(%q) error: %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseCustomID(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCustomIDString(t *testing.T) {
+	tests := []struct {
+		id   CustomID
+		want string
+	}{
+		{id: CustomID{Handler: "commands"}, want: "commands"},
+		{id: CustomID{Handler: "commands", Action: "cancel"}, want: "commands:cancel"},
+		{id: CustomID{Handler: "commands", Action: "reset", State: "ping"}, want: "commands:reset:ping"},
+		{id: CustomID{Handler: "commands", State: "state"}, want: "commands::state"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.id.String(); got != tt.want {
+				t.Errorf("%+v.String() = %q, want %q", tt.id, got, tt.want)
+			}
+
+			parsed, err := ParseCustomID(tt.want)
+			if err != nil || parsed != tt.id {
+				t.Errorf("ParseCustomID(%q) = %+v, %v, want %+v", tt.want, parsed, err, tt.id)
+			}
+		})
+	}
+}
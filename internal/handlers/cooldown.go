@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/glotchimo/recast/internal/utils"
+)
+
+type CooldownScope int
+
+const (
+	CooldownUser CooldownScope = iota
+	CooldownGuild
+	CooldownChannel
+)
+
+type Cooldown struct {
+	Scope    CooldownScope
+	Duration time.Duration
+	Burst    int
+}
+
+type Throttled interface {
+	Cooldown() Cooldown
+}
+
+func (c Cooldown) key(name string, i *dg.InteractionCreate) string {
+	switch c.Scope {
+	case CooldownGuild:
+		return fmt.Sprintf("cooldown:%s:guild:%s", name, i.GuildID)
+	case CooldownChannel:
+		return fmt.Sprintf("cooldown:%s:channel:%s", name, i.ChannelID)
+	default:
+		return fmt.Sprintf("cooldown:%s:user:%s", name, i.Member.User.ID)
+	}
+}
+
+func Throttle() Middleware {
+	return func(next Handler) Handler {
+		return Wrap(next, func(ctx context.Context, dep Dependencies) error {
+			t, ok := Unwrap(next).(Throttled)
+			if !ok || dep.Interaction.Type != dg.InteractionApplicationCommand {
+				return next.Handle(ctx, dep)
+			}
+
+			cd := t.Cooldown()
+			if cd.Duration <= 0 {
+				return next.Handle(ctx, dep)
+			}
+
+			burst := cd.Burst
+			if burst < 1 {
+				burst = 1
+			}
+
+			name := next.Metadata().Name
+			count, remaining, err := dep.Cache.Incr(ctx, cd.key(name, dep.Interaction), cd.Duration)
+			if err != nil {
+				dep.Logger.Warn("error checking cooldown", "error", err, "command", name, "guild", dep.Interaction.GuildID)
+				return next.Handle(ctx, dep)
+			}
+
+			if count > int64(burst) {
+				return utils.Failure{
+					Type:    utils.ErrCooldown,
+					Message: fmt.Sprintf("You can use `/%s` again in %s.", name, utils.FormatDuration(remaining)),
+					Data:    map[string]any{"command": name, "remaining": remaining},
+				}
+			}
+
+			return next.Handle(ctx, dep)
+		})
+	}
+}
@@ -9,20 +9,49 @@ import (
 	db "github.com/glotchimo/recast/internal/database"
 	md "github.com/glotchimo/recast/internal/models"
 	rp "github.com/glotchimo/recast/internal/response"
+	"github.com/glotchimo/recast/internal/session"
 )
 
 type Dependencies struct {
-	Session     *dg.Session
-	Database    *db.Database
+	Session     session.Session
+	Database    db.Store
 	Cache       *ch.Cache
 	Responder   *rp.Responder
+	Registry    Registry
 	Logger      *slog.Logger
 	Guild       *md.Guild
 	Interaction *dg.InteractionCreate
 	Options     *map[string]*dg.ApplicationCommandInteractionDataOption
+	Relay       func(message string)
+	Subcommand  string
+	CustomID    *CustomID
+
+	TargetUser    *dg.User
+	TargetMember  *dg.Member
+	TargetMessage *dg.Message
 }
 
+type HandlerFunc func(context.Context, Dependencies) error
+
 type Handler interface {
 	Metadata() dg.ApplicationCommand
 	Handle(context.Context, Dependencies) error
 }
+
+type Autocompleter interface {
+	Autocomplete(context.Context, Dependencies, *dg.ApplicationCommandInteractionDataOption) ([]*dg.ApplicationCommandOptionChoice, error)
+}
+
+type Router interface {
+	Subcommands() map[string]HandlerFunc
+}
+
+type Scoped interface {
+	Guilds() []string
+}
+
+type Registry interface {
+	Commands() []dg.ApplicationCommand
+	Reload(guildID string) error
+	Overridable(guildID, name string) bool
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"time"
+
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/glotchimo/recast/internal/metrics"
+	md "github.com/glotchimo/recast/internal/models"
+	"github.com/glotchimo/recast/internal/tracing"
+	"github.com/glotchimo/recast/internal/utils"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type Middleware func(Handler) Handler
+
+type wrapped struct {
+	Handler
+	fn HandlerFunc
+}
+
+func (w wrapped) Handle(ctx context.Context, dep Dependencies) error {
+	return w.fn(ctx, dep)
+}
+
+func (w wrapped) Unwrap() Handler {
+	return w.Handler
+}
+
+func Wrap(h Handler, fn HandlerFunc) Handler {
+	return wrapped{Handler: h, fn: fn}
+}
+
+func Unwrap(h Handler) Handler {
+	for {
+		w, ok := h.(interface{ Unwrap() Handler })
+		if !ok {
+			return h
+		}
+		h = w.Unwrap()
+	}
+}
+
+func Chain(h Handler, mws ...Middleware) Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+func Trace() Middleware {
+	return func(next Handler) Handler {
+		return Wrap(next, func(ctx context.Context, dep Dependencies) error {
+			attrs := []attribute.KeyValue{
+				attribute.String("interaction.id", dep.Interaction.ID),
+				attribute.String("guild.id", dep.Interaction.GuildID),
+				attribute.String("command.name", next.Metadata().Name),
+			}
+			if dep.Subcommand != "" {
+				attrs = append(attrs, attribute.String("command.subcommand", dep.Subcommand))
+			}
+
+			ctx, span := tracing.Start(ctx, "handle", attrs...)
+			defer span.End()
+
+			err := next.Handle(ctx, dep)
+			tracing.Fail(span, err)
+			return err
+		})
+	}
+}
+
+func Persist(record func(md.Interaction)) Middleware {
+	return func(next Handler) Handler {
+		return Wrap(next, func(ctx context.Context, dep Dependencies) error {
+			start := time.Now().UTC()
+
+			err := next.Handle(ctx, dep)
+
+			outcome := md.OutcomeOK
+			var f utils.Failure
+			switch {
+			case err == nil:
+			case errors.As(err, &f):
+				outcome = md.OutcomeFailure
+			default:
+				outcome = md.OutcomeError
+			}
+
+			record(md.Interaction{
+				Interaction: dep.Interaction.Interaction,
+				CommandName: next.Metadata().Name,
+				Outcome:     outcome,
+				Created:     start,
+			})
+
+			return err
+		})
+	}
+}
+
+func Log() Middleware {
+	return func(next Handler) Handler {
+		return Wrap(next, func(ctx context.Context, dep Dependencies) error {
+			i := dep.Interaction
+
+			switch i.Type {
+			case dg.InteractionApplicationCommand:
+				dep.Logger.Info("command issued", "user", i.Member.User.Username, "called", utils.FormatInteraction(i))
+			case dg.InteractionMessageComponent:
+				data := i.MessageComponentData()
+				dep.Logger.Info("component used", "user", i.Member.User.Username, "custom_id", data.CustomID, "values", data.Values)
+			case dg.InteractionModalSubmit:
+				dep.Logger.Info("form submitted", "user", i.Member.User.Username, "custom_id", i.ModalSubmitData().CustomID)
+			}
+
+			return next.Handle(ctx, dep)
+		})
+	}
+}
+
+func Audit() Middleware {
+	return func(next Handler) Handler {
+		return Wrap(next, func(ctx context.Context, dep Dependencies) error {
+			if called := utils.FormatInteraction(dep.Interaction); called != "" {
+				dep.Relay(fmt.Sprintf("%s used `%s`", utils.FormatUserMention(md.Interaction{Interaction: dep.Interaction.Interaction}.UserID()), called))
+			}
+
+			return next.Handle(ctx, dep)
+		})
+	}
+}
+
+func Report() Middleware {
+	return func(next Handler) Handler {
+		return Wrap(next, func(ctx context.Context, dep Dependencies) error {
+			err := next.Handle(ctx, dep)
+			if err == nil {
+				return nil
+			}
+
+			var f utils.Failure
+			if errors.As(err, &f) {
+				dep.Responder.Fail(dep.Interaction, f)
+				return nil
+			}
+
+			dep.Logger.Error("error handling command", "error", err, "command", next.Metadata().Name, "guild", dep.Interaction.GuildID)
+			dep.Responder.Fail(dep.Interaction, utils.Failure{
+				Type:    utils.ErrInternal,
+				Message: "Failed to handle command",
+				Data:    map[string]any{"error": err},
+			})
+
+			return nil
+		})
+	}
+}
+
+func Recover() Middleware {
+	return func(next Handler) Handler {
+		return Wrap(next, func(ctx context.Context, dep Dependencies) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					stack := make([]byte, 4096)
+					stack = stack[:runtime.Stack(stack, false)]
+					dep.Logger.Error("panic recovered", "command", next.Metadata().Name, "guild", dep.Interaction.GuildID, "recovered", r, "stack", stack)
+					err = fmt.Errorf("panic: %v", r)
+				}
+			}()
+
+			return next.Handle(ctx, dep)
+		})
+	}
+}
+
+func Measure() Middleware {
+	return func(next Handler) Handler {
+		return Wrap(next, func(ctx context.Context, dep Dependencies) error {
+			name := next.Metadata().Name
+			start := time.Now()
+
+			err := next.Handle(ctx, dep)
+
+			metrics.CommandDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+
+			var f utils.Failure
+			switch {
+			case err == nil:
+				metrics.CommandsHandled.WithLabelValues(name, "ok", "").Inc()
+			case errors.As(err, &f):
+				metrics.CommandsHandled.WithLabelValues(name, "failure", f.Type.String()).Inc()
+			default:
+				metrics.CommandsHandled.WithLabelValues(name, "error", utils.ErrInternal.String()).Inc()
+			}
+
+			return err
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"slices"
+
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/glotchimo/recast/internal/utils"
+)
+
+type Requirements struct {
+	Permissions int64
+	Roles       []string
+	OwnerOnly   bool
+}
+
+type Restricted interface {
+	Requirements() Requirements
+}
+
+func (r Requirements) check(m *dg.Member, isOwner func(string) bool) (string, bool) {
+	if r.OwnerOnly && !isOwner(m.User.ID) {
+		return "This command is restricted to the bot owners.", false
+	}
+
+	if m.Permissions&dg.PermissionAdministrator != 0 {
+		return "", true
+	}
+
+	if m.Permissions&r.Permissions != r.Permissions {
+		return "You don't have the permissions required to use this command.", false
+	}
+
+	if len(r.Roles) > 0 && !slices.ContainsFunc(m.Roles, func(id string) bool { return slices.Contains(r.Roles, id) }) {
+		return "You don't have a role required to use this command.", false
+	}
+
+	return "", true
+}
+
+func Authorize(isOwner func(userID string) bool) Middleware {
+	return func(next Handler) Handler {
+		return Wrap(next, func(ctx context.Context, dep Dependencies) error {
+			r, ok := Unwrap(next).(Restricted)
+			if !ok {
+				return next.Handle(ctx, dep)
+			}
+
+			if reason, ok := r.Requirements().check(dep.Interaction.Member, isOwner); !ok {
+				return utils.Failure{
+					Type:    utils.ErrNotAllowed,
+					Message: reason,
+					Data:    map[string]any{"command": next.Metadata().Name, "user": dep.Interaction.Member.User.ID},
+				}
+			}
+
+			return next.Handle(ctx, dep)
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"testing"
+
+	dg "github.com/bwmarrin/discordgo"
+)
+
+func TestRequirementsCheck(t *testing.T) {
+	isOwner := func(userID string) bool { return userID == "owner" }
+	member := func(userID string, permissions int64, roles ...string) *dg.Member {
+		return &dg.Member{User: &dg.User{ID: userID}, Permissions: permissions, Roles: roles}
+	}
+
+	tests := []struct {
+		name string
+		r    Requirements
+		m    *dg.Member
+		want bool
+	}{
+		{name: "no requirements", m: member("1", 0), want: true},
+		{name: "permission held", r: Requirements{Permissions: dg.PermissionManageGuild}, m: member("1", dg.PermissionManageGuild|dg.PermissionManageMessages), want: true},
+		{name: "permission missing", r: Requirements{Permissions: dg.PermissionManageGuild}, m: member("1", dg.PermissionManageMessages)},
+		{name: "one of several permissions", r: Requirements{Permissions: dg.PermissionManageGuild | dg.PermissionManageRoles}, m: member("1", dg.PermissionManageGuild)},
+		{name: "administrator", r: Requirements{Permissions: dg.PermissionManageGuild, Roles: []string{"mod"}}, m: member("1", dg.PermissionAdministrator), want: true},
+		{name: "role held", r: Requirements{Roles: []string{"mod", "admin"}}, m: member("1", 0, "member", "admin"), want: true},
+		{name: "role missing", r: Requirements{Roles: []string{"mod"}}, m: member("1", 0, "member")},
+		{name: "permission without role", r: Requirements{Permissions: dg.PermissionManageGuild, Roles: []string{"mod"}}, m: member("1", dg.PermissionManageGuild)},
+		{name: "owner", r: Requirements{OwnerOnly: true}, m: member("owner", 0), want: true},
+		{name: "not owner", r: Requirements{OwnerOnly: true}, m: member("1", 0)},
+		{name: "administrator not owner", r: Requirements{OwnerOnly: true}, m: member("1", dg.PermissionAdministrator)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, ok := tt.r.check(tt.m, isOwner)
+			if ok != tt.want {
+				t.Fatalf("check() = %q, %t, want %t", reason, ok, tt.want)
+			}
+			if !ok && reason == "" {
+				t.Errorf("check() denied without a reason")
+			}
+		})
+	}
+}
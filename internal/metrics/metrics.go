@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "recast"
+
+var (
+	CommandsHandled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "commands_handled_total",
+		Help:      "Commands handled, by command, outcome and failure type.",
+	}, []string{"command", "outcome", "error_type"})
+
+	CommandDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "command_duration_seconds",
+		Help:      "Time spent in command handlers.",
+		Buckets:   []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"command"})
+
+	QueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "guild_event_queue_depth",
+		Help:      "Events waiting in each guild's event queue.",
+	}, []string{"guild"})
+
+	EventsDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_dropped_total",
+		Help:      "Gateway events dropped before reaching a guild's event queue.",
+	}, []string{"reason"})
+
+	QueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "database_query_duration_seconds",
+		Help:      "Database query latency, by operation and table.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "table"})
+
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Cache requests, by operation and result.",
+	}, []string{"operation", "result"})
+
+	BreakerState = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cache_circuit_breaker_state",
+		Help:      "Redis circuit breaker state: 0 closed, 1 open, 2 half-open.",
+	})
+
+	BreakerTransitions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_circuit_breaker_transitions_total",
+		Help:      "Redis circuit breaker state transitions.",
+	}, []string{"from", "to"})
+
+	RowsWritten = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "batch_rows_total",
+		Help:      "Rows handled by batched writers, by table and result.",
+	}, []string{"table", "result"})
+
+	RowsBuffered = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "batch_rows_buffered",
+		Help:      "Rows waiting in batched writers, including rows being retried.",
+	}, []string{"table"})
+
+	RelayMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "relay_messages_total",
+		Help:      "Messages relayed to guild log channels, by result.",
+	}, []string{"result"})
+)
+
+func ObserveQuery(operation, table string) func() {
+	start := time.Now()
+	return func() {
+		QueryDuration.WithLabelValues(operation, table).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type Bot struct {
+	ID       string
+	Settings struct {
+		CommandSetHash string `json:"command_set_hash"`
+	}
+	Created time.Time
+	Updated time.Time
+}
+
+func (b Bot) Map() map[string]any {
+	settings, _ := json.Marshal(b.Settings)
+
+	return map[string]any{
+		"id":       b.ID,
+		"settings": settings,
+		"created":  b.Created,
+	}
+}
+
+func (b Bot) Table() Table {
+	return TableBot
+}
@@ -9,14 +9,34 @@ type Guild struct {
 	ID       string
 	Name     string
 	Settings struct {
-		LogChannelID   string `json:"log_channel_id"`
-		CommandSetHash string `json:"command_set_hash"`
+		LogChannelID   string                     `json:"log_channel_id"`
+		CommandSetHash string                     `json:"command_set_hash"`
+		Commands       map[string]CommandSettings `json:"commands,omitempty"`
+		RetentionDays  *int                       `json:"retention_days,omitempty"`
 	}
 	Created time.Time
 	Updated time.Time
 	Deleted *time.Time
 }
 
+type CommandSettings struct {
+	Disabled bool   `json:"disabled,omitempty"`
+	Alias    string `json:"alias,omitempty"`
+}
+
+func (g Guild) CommandName(invoked string) string {
+	for name, cs := range g.Settings.Commands {
+		if cs.Alias != "" && cs.Alias == invoked {
+			return name
+		}
+	}
+	return invoked
+}
+
+func (g Guild) CommandEnabled(name string) bool {
+	return !g.Settings.Commands[name].Disabled
+}
+
 func (g Guild) Map() map[string]any {
 	settings, _ := json.Marshal(g.Settings)
 
@@ -25,6 +45,7 @@ func (g Guild) Map() map[string]any {
 		"name":     g.Name,
 		"settings": settings,
 		"created":  g.Created,
+		"deleted":  g.Deleted,
 	}
 }
 
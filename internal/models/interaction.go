@@ -2,23 +2,104 @@ package models
 
 import (
 	"encoding/json"
+	"strings"
 	"time"
 
 	dg "github.com/bwmarrin/discordgo"
 )
 
+const (
+	OutcomeOK      = "ok"
+	OutcomeFailure = "failure"
+	OutcomeError   = "error"
+)
+
 type Interaction struct {
 	Interaction *dg.Interaction
+	// CommandName is the handler's own name, which Command falls back to
+	// deriving from the interaction when unset. Invocations carry the
+	// guild's alias instead.
+	CommandName string
+	Outcome     string
 	Created     time.Time
 }
 
+type ArchivedInteraction struct {
+	ID          string          `json:"id,omitempty"`
+	GuildID     string          `json:"guild_id,omitempty"`
+	UserID      string          `json:"user_id,omitempty"`
+	Command     string          `json:"command,omitempty"`
+	Created     time.Time       `json:"created"`
+	Interaction json.RawMessage `json:"interaction"`
+}
+
 func (i Interaction) Map() map[string]any {
 	ib, _ := json.Marshal(i.Interaction)
 	return map[string]any{
+		"id":          nullable(i.ID()),
+		"guild_id":    nullable(i.GuildID()),
+		"user_id":     nullable(i.UserID()),
+		"command":     nullable(i.Command()),
+		"outcome":     nullable(i.Outcome),
 		"interaction": ib,
+		"created":     i.Created,
 	}
 }
 
 func (i Interaction) Table() Table {
 	return TableInteractions
 }
+
+func (i Interaction) ID() string {
+	if i.Interaction == nil {
+		return ""
+	}
+	return i.Interaction.ID
+}
+
+func (i Interaction) GuildID() string {
+	if i.Interaction == nil {
+		return ""
+	}
+	return i.Interaction.GuildID
+}
+
+func (i Interaction) UserID() string {
+	switch {
+	case i.Interaction == nil:
+		return ""
+	case i.Interaction.Member != nil && i.Interaction.Member.User != nil:
+		return i.Interaction.Member.User.ID
+	case i.Interaction.User != nil:
+		return i.Interaction.User.ID
+	}
+	return ""
+}
+
+func (i Interaction) Command() string {
+	if i.CommandName != "" {
+		return i.CommandName
+	}
+	if i.Interaction == nil {
+		return ""
+	}
+
+	switch data := i.Interaction.Data.(type) {
+	case dg.ApplicationCommandInteractionData:
+		return data.Name
+	case dg.MessageComponentInteractionData:
+		handler, _, _ := strings.Cut(data.CustomID, ":")
+		return handler
+	case dg.ModalSubmitInteractionData:
+		handler, _, _ := strings.Cut(data.CustomID, ":")
+		return handler
+	}
+	return ""
+}
+
+func nullable(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
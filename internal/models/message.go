@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	dg "github.com/bwmarrin/discordgo"
+)
+
+type Message struct {
+	ID          string       `json:"id"`
+	GuildID     string       `json:"guild_id"`
+	ChannelID   string       `json:"channel_id"`
+	AuthorID    string       `json:"author_id"`
+	AuthorName  string       `json:"author_name"`
+	Content     string       `json:"content,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+	Created     time.Time    `json:"created"`
+	Edited      *time.Time   `json:"edited,omitempty"`
+}
+
+type Attachment struct {
+	Filename string `json:"filename"`
+	URL      string `json:"url"`
+	Size     int    `json:"size"`
+}
+
+func NewMessage(m *dg.Message) Message {
+	msg := Message{
+		ID:        m.ID,
+		GuildID:   m.GuildID,
+		ChannelID: m.ChannelID,
+		Content:   m.Content,
+		Created:   m.Timestamp,
+		Edited:    m.EditedTimestamp,
+	}
+
+	if m.Author != nil {
+		msg.AuthorID = m.Author.ID
+		msg.AuthorName = m.Author.Username
+	}
+
+	for _, a := range m.Attachments {
+		msg.Attachments = append(msg.Attachments, Attachment{Filename: a.Filename, URL: a.URL, Size: a.Size})
+	}
+
+	return msg
+}
+
+// Apply merges an edit into the message. Edits only carry the fields that
+// changed, so an empty field keeps the cached value.
+func (msg *Message) Apply(m *dg.Message) {
+	if m.Content != "" {
+		msg.Content = m.Content
+	}
+	if m.Attachments != nil {
+		msg.Attachments = NewMessage(m).Attachments
+	}
+	if m.EditedTimestamp != nil {
+		msg.Edited = m.EditedTimestamp
+	}
+}
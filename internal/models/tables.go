@@ -3,6 +3,12 @@ package models
 type Table string
 
 const (
-	TableGuilds       Table = "guilds"
-	TableInteractions Table = "interactions"
+	TableBot               Table = "bot"
+	TableGuilds            Table = "guilds"
+	TableInteractions      Table = "interactions"
+	TableCommandUsageDaily Table = "command_usage_daily"
+	TableGuildUsageDaily   Table = "guild_usage_daily"
+	TableUserUsageDaily    Table = "user_usage_daily"
+	TableVoiceEvents       Table = "voice_events"
+	TableVoiceSessions     Table = "voice_sessions"
 )
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+type CommandUsage struct {
+	Command  string
+	Uses     int
+	Failures int
+	Errors   int
+}
+
+type DailyUsage struct {
+	Day          time.Time
+	Interactions int
+	Users        int
+}
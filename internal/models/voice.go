@@ -0,0 +1,73 @@
+package models
+
+import "time"
+
+const (
+	VoiceJoin     = "join"
+	VoiceLeave    = "leave"
+	VoiceMove     = "move"
+	VoiceMute     = "mute"
+	VoiceUnmute   = "unmute"
+	VoiceDeafen   = "deafen"
+	VoiceUndeafen = "undeafen"
+)
+
+type VoiceEvent struct {
+	GuildID   string
+	UserID    string
+	ChannelID string
+	Type      string
+	Created   time.Time
+}
+
+func (e VoiceEvent) Map() map[string]any {
+	return map[string]any{
+		"guild_id":   e.GuildID,
+		"user_id":    e.UserID,
+		"channel_id": nullable(e.ChannelID),
+		"type":       e.Type,
+		"created":    e.Created,
+	}
+}
+
+func (e VoiceEvent) Table() Table {
+	return TableVoiceEvents
+}
+
+type VoiceSession struct {
+	GuildID   string
+	UserID    string
+	ChannelID string
+	Muted     bool
+	Deafened  bool
+	Created   time.Time
+	Ended     *time.Time
+}
+
+func (s VoiceSession) Map() map[string]any {
+	return map[string]any{
+		"guild_id":   s.GuildID,
+		"user_id":    s.UserID,
+		"channel_id": s.ChannelID,
+		"muted":      s.Muted,
+		"deafened":   s.Deafened,
+		"created":    s.Created,
+	}
+}
+
+func (s VoiceSession) Table() Table {
+	return TableVoiceSessions
+}
+
+func (s VoiceSession) Duration(now time.Time) time.Duration {
+	if s.Ended != nil {
+		now = *s.Ended
+	}
+	return now.Sub(s.Created).Truncate(time.Second)
+}
+
+type VoiceTotal struct {
+	UserID   string
+	Sessions int
+	Duration time.Duration
+}
@@ -2,11 +2,13 @@ package response
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 
 	dg "github.com/bwmarrin/discordgo"
 	"github.com/glotchimo/recast/internal/database"
+	"github.com/glotchimo/recast/internal/session"
 	"github.com/glotchimo/recast/internal/utils"
 )
 
@@ -22,13 +24,15 @@ type MessageOptions struct {
 }
 
 type Responder struct {
-	s   *dg.Session
+	s   session.Session
 	l   *slog.Logger
-	d   *database.Database
+	d   database.Store
 	ctx context.Context
+
+	onFail func(*dg.InteractionCreate, utils.Failure)
 }
 
-func NewSessionResponder(s *dg.Session, l *slog.Logger, d *database.Database, ctx context.Context) *Responder {
+func NewSessionResponder(s session.Session, l *slog.Logger, d database.Store, ctx context.Context) *Responder {
 	return &Responder{
 		s:   s,
 		l:   l,
@@ -37,6 +41,10 @@ func NewSessionResponder(s *dg.Session, l *slog.Logger, d *database.Database, ct
 	}
 }
 
+func (r *Responder) OnFail(fn func(*dg.InteractionCreate, utils.Failure)) {
+	r.onFail = fn
+}
+
 func (r *Responder) Defer(i *dg.InteractionCreate, ephemeral bool) error {
 	var err error
 	if ephemeral {
@@ -55,6 +63,64 @@ func (r *Responder) Defer(i *dg.InteractionCreate, ephemeral bool) error {
 	return err
 }
 
+func (r *Responder) DeferUpdate(i *dg.InteractionCreate) error {
+	return r.s.InteractionRespond(i.Interaction, &dg.InteractionResponse{
+		Type: dg.InteractionResponseDeferredMessageUpdate,
+	})
+}
+
+func (r *Responder) Update(i *dg.InteractionCreate, opts MessageOptions) error {
+	return r.s.InteractionRespond(i.Interaction, &dg.InteractionResponse{
+		Type: dg.InteractionResponseUpdateMessage,
+		Data: &dg.InteractionResponseData{
+			Content:    opts.Content,
+			Embeds:     opts.Embeds,
+			Files:      opts.Files,
+			Components: opts.Components,
+		},
+	})
+}
+
+func (r *Responder) EditOriginal(i *dg.InteractionCreate, opts MessageOptions) error {
+	edit := &dg.WebhookEdit{
+		Content:    &opts.Content,
+		Embeds:     &opts.Embeds,
+		Components: &opts.Components,
+	}
+
+	_, err := r.s.InteractionResponseEdit(i.Interaction, edit)
+	return err
+}
+
+func (r *Responder) Modal(i *dg.InteractionCreate, customID, title string, inputs ...dg.TextInput) error {
+	rows := make([]dg.MessageComponent, 0, len(inputs))
+	for _, input := range inputs {
+		rows = append(rows, dg.ActionsRow{Components: []dg.MessageComponent{input}})
+	}
+
+	return r.s.InteractionRespond(i.Interaction, &dg.InteractionResponse{
+		Type: dg.InteractionResponseModal,
+		Data: &dg.InteractionResponseData{
+			CustomID:   customID,
+			Title:      title,
+			Components: rows,
+		},
+	})
+}
+
+func (r *Responder) Autocomplete(i *dg.InteractionCreate, choices []*dg.ApplicationCommandOptionChoice) error {
+	if choices == nil {
+		choices = []*dg.ApplicationCommandOptionChoice{}
+	}
+
+	return r.s.InteractionRespond(i.Interaction, &dg.InteractionResponse{
+		Type: dg.InteractionApplicationCommandAutocompleteResult,
+		Data: &dg.InteractionResponseData{
+			Choices: utils.CapChoices(choices),
+		},
+	})
+}
+
 func (r *Responder) Send(i *dg.InteractionCreate, opts MessageOptions) error {
 	params := &dg.WebhookParams{
 		Content:    opts.Content,
@@ -102,6 +168,9 @@ func (r *Responder) Delete(i *dg.InteractionCreate, messageID string) error {
 
 func (r *Responder) Fail(i *dg.InteractionCreate, ctx utils.Failure) error {
 	r.l.Warn("handler failure", "type", ctx.Type, "message", ctx.Message, "data", ctx.Data)
+	if r.onFail != nil {
+		r.onFail(i, ctx)
+	}
 
 	var title, description string
 	var color int
@@ -152,8 +221,28 @@ func (r *Responder) Fail(i *dg.InteractionCreate, ctx utils.Failure) error {
 		Color:       color,
 	}
 
-	_, err := r.s.FollowupMessageCreate(i.Interaction, true, &dg.WebhookParams{
+	err := r.s.InteractionRespond(i.Interaction, &dg.InteractionResponse{
+		Type: dg.InteractionResponseChannelMessageWithSource,
+		Data: &dg.InteractionResponseData{
+			Embeds: []*dg.MessageEmbed{embed},
+			Flags:  dg.MessageFlagsEphemeral,
+		},
+	})
+	if !acknowledged(err) {
+		return err
+	}
+
+	_, err = r.s.FollowupMessageCreate(i.Interaction, true, &dg.WebhookParams{
 		Embeds: []*dg.MessageEmbed{embed},
+		Flags:  dg.MessageFlagsEphemeral,
 	})
 	return err
 }
+
+func acknowledged(err error) bool {
+	var restErr *dg.RESTError
+	if !errors.As(err, &restErr) || restErr.Message == nil {
+		return false
+	}
+	return restErr.Message.Code == dg.ErrCodeInteractionHasAlreadyBeenAcknowledged
+}
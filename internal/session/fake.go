@@ -0,0 +1,145 @@
+package session
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	dg "github.com/bwmarrin/discordgo"
+)
+
+type Call struct {
+	Method      string
+	Interaction *dg.Interaction
+	Response    *dg.InteractionResponse
+	Params      *dg.WebhookParams
+	Edit        *dg.WebhookEdit
+	Message     *dg.MessageSend
+	Commands    []*dg.ApplicationCommand
+	ChannelID   string
+	GuildID     string
+	MessageID   string
+}
+
+type Fake struct {
+	mu      sync.Mutex
+	state   *dg.State
+	calls   []Call
+	next    int
+	latency time.Duration
+	acked   map[string]bool
+
+	Err func(Call) error
+}
+
+func NewFake(botID string) *Fake {
+	state := dg.NewState()
+	state.User = &dg.User{ID: botID, Username: "recast", Bot: true}
+	state.Ready.User = state.User
+
+	return &Fake{state: state, latency: 42 * time.Millisecond, acked: map[string]bool{}}
+}
+
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.calls)
+}
+
+func (f *Fake) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = nil
+	f.acked = map[string]bool{}
+}
+
+func (f *Fake) record(c Call) (*dg.Message, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, c)
+	f.next++
+	id := fmt.Sprintf("%d", f.next)
+	fail := f.Err
+	f.mu.Unlock()
+
+	if fail != nil {
+		if err := fail(c); err != nil {
+			return nil, err
+		}
+	}
+
+	channelID := c.ChannelID
+	if c.Interaction != nil {
+		channelID = c.Interaction.ChannelID
+	}
+	if c.MessageID != "" {
+		id = c.MessageID
+	}
+
+	return &dg.Message{ID: id, ChannelID: channelID, GuildID: c.GuildID}, nil
+}
+
+func (f *Fake) State() *dg.State {
+	return f.state
+}
+
+func (f *Fake) HeartbeatLatency() time.Duration {
+	return f.latency
+}
+
+// InteractionRespond fails like Discord does when the interaction already
+// has a response, so fallbacks to followups can be exercised.
+func (f *Fake) InteractionRespond(i *dg.Interaction, resp *dg.InteractionResponse, options ...dg.RequestOption) error {
+	if _, err := f.record(Call{Method: "InteractionRespond", Interaction: i, Response: resp, GuildID: i.GuildID}); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.acked[i.ID] {
+		return alreadyAcknowledged()
+	}
+	f.acked[i.ID] = true
+	return nil
+}
+
+func alreadyAcknowledged() error {
+	body := fmt.Sprintf(`{"message": "Interaction has already been acknowledged.", "code": %d}`, dg.ErrCodeInteractionHasAlreadyBeenAcknowledged)
+	return &dg.RESTError{
+		Response:     &http.Response{StatusCode: http.StatusBadRequest, Status: "400 Bad Request"},
+		ResponseBody: []byte(body),
+		Message:      &dg.APIErrorMessage{Code: dg.ErrCodeInteractionHasAlreadyBeenAcknowledged, Message: "Interaction has already been acknowledged."},
+	}
+}
+
+func (f *Fake) InteractionResponseEdit(i *dg.Interaction, edit *dg.WebhookEdit, options ...dg.RequestOption) (*dg.Message, error) {
+	return f.record(Call{Method: "InteractionResponseEdit", Interaction: i, Edit: edit, GuildID: i.GuildID})
+}
+
+func (f *Fake) FollowupMessageCreate(i *dg.Interaction, wait bool, params *dg.WebhookParams, options ...dg.RequestOption) (*dg.Message, error) {
+	return f.record(Call{Method: "FollowupMessageCreate", Interaction: i, Params: params, GuildID: i.GuildID})
+}
+
+func (f *Fake) FollowupMessageEdit(i *dg.Interaction, messageID string, edit *dg.WebhookEdit, options ...dg.RequestOption) (*dg.Message, error) {
+	return f.record(Call{Method: "FollowupMessageEdit", Interaction: i, Edit: edit, GuildID: i.GuildID, MessageID: messageID})
+}
+
+func (f *Fake) FollowupMessageDelete(i *dg.Interaction, messageID string, options ...dg.RequestOption) error {
+	_, err := f.record(Call{Method: "FollowupMessageDelete", Interaction: i, GuildID: i.GuildID, MessageID: messageID})
+	return err
+}
+
+func (f *Fake) ChannelMessageSendComplex(channelID string, data *dg.MessageSend, options ...dg.RequestOption) (*dg.Message, error) {
+	return f.record(Call{Method: "ChannelMessageSendComplex", ChannelID: channelID, Message: data})
+}
+
+func (f *Fake) ApplicationCommandBulkOverwrite(appID, guildID string, commands []*dg.ApplicationCommand, options ...dg.RequestOption) ([]*dg.ApplicationCommand, error) {
+	if _, err := f.record(Call{Method: "ApplicationCommandBulkOverwrite", GuildID: guildID, Commands: commands}); err != nil {
+		return nil, err
+	}
+	return commands, nil
+}
+
+var _ Session = (*Fake)(nil)
@@ -0,0 +1,34 @@
+package session
+
+import (
+	"time"
+
+	dg "github.com/bwmarrin/discordgo"
+)
+
+type Session interface {
+	State() *dg.State
+	HeartbeatLatency() time.Duration
+
+	InteractionRespond(i *dg.Interaction, resp *dg.InteractionResponse, options ...dg.RequestOption) error
+	InteractionResponseEdit(i *dg.Interaction, edit *dg.WebhookEdit, options ...dg.RequestOption) (*dg.Message, error)
+	FollowupMessageCreate(i *dg.Interaction, wait bool, params *dg.WebhookParams, options ...dg.RequestOption) (*dg.Message, error)
+	FollowupMessageEdit(i *dg.Interaction, messageID string, edit *dg.WebhookEdit, options ...dg.RequestOption) (*dg.Message, error)
+	FollowupMessageDelete(i *dg.Interaction, messageID string, options ...dg.RequestOption) error
+
+	ChannelMessageSendComplex(channelID string, data *dg.MessageSend, options ...dg.RequestOption) (*dg.Message, error)
+
+	ApplicationCommandBulkOverwrite(appID, guildID string, commands []*dg.ApplicationCommand, options ...dg.RequestOption) ([]*dg.ApplicationCommand, error)
+}
+
+type discord struct {
+	*dg.Session
+}
+
+func Wrap(s *dg.Session) Session {
+	return discord{s}
+}
+
+func (d discord) State() *dg.State {
+	return d.Session.State
+}
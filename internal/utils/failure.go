@@ -11,6 +11,24 @@ const (
 	ErrTooLarge
 )
 
+func (t ErrorType) String() string {
+	switch t {
+	case ErrInternal:
+		return "internal"
+	case ErrBadInput:
+		return "bad_input"
+	case ErrNotAllowed:
+		return "not_allowed"
+	case ErrCooldown:
+		return "cooldown"
+	case ErrNotFound:
+		return "not_found"
+	case ErrTooLarge:
+		return "too_large"
+	}
+	return "unknown"
+}
+
 type Failure struct {
 	Type    ErrorType
 	Message string
@@ -104,6 +104,16 @@ func formatValue(b *strings.Builder, v any, level int) {
 }
 
 func FormatDuration(d time.Duration) string {
+	if d > 0 && d < time.Minute {
+		s := (d + time.Second - 1) / time.Second
+		if s == 1 {
+			return "1 second"
+		}
+		if s < 60 {
+			return fmt.Sprintf("%d seconds", s)
+		}
+	}
+
 	d = d.Round(time.Minute)
 
 	years := d / (365 * 24 * time.Hour)
@@ -196,22 +206,32 @@ func FormatRoleMention(id string) string {
 	return fmt.Sprintf("<@&%s>", id)
 }
 
-func FormatInteraction(s *dg.Session, i *dg.InteractionCreate) string {
+func FormatInteraction(i *dg.InteractionCreate) string {
 	if i.Type != dg.InteractionApplicationCommand {
 		return ""
 	}
 
 	data := i.ApplicationCommandData()
+
+	switch data.CommandType {
+	case dg.UserApplicationCommand:
+		return fmt.Sprintf("%s (user %s)", data.Name, data.TargetID)
+	case dg.MessageApplicationCommand:
+		return fmt.Sprintf("%s (message %s)", data.Name, data.TargetID)
+	}
+
 	parts := []string{"/" + data.Name}
 
 	for _, opt := range data.Options {
-		parts = append(parts, formatCommandOption(s, i.GuildID, opt))
+		parts = append(parts, formatCommandOption(data.Resolved, opt))
 	}
 
 	return strings.Join(parts, " ")
 }
 
-func formatCommandValue(s *dg.Session, guildID string, opt *dg.ApplicationCommandInteractionDataOption) string {
+func formatCommandValue(resolved *dg.ApplicationCommandInteractionDataResolved, opt *dg.ApplicationCommandInteractionDataOption) string {
+	id, _ := opt.Value.(string)
+
 	switch opt.Type {
 	case dg.ApplicationCommandOptionString:
 		return opt.StringValue()
@@ -220,11 +240,20 @@ func formatCommandValue(s *dg.Session, guildID string, opt *dg.ApplicationComman
 	case dg.ApplicationCommandOptionBoolean:
 		return fmt.Sprintf("%t", opt.BoolValue())
 	case dg.ApplicationCommandOptionUser:
-		return opt.UserValue(s).Username
+		if resolved != nil && resolved.Users[id] != nil {
+			return resolved.Users[id].Username
+		}
+		return id
 	case dg.ApplicationCommandOptionChannel:
-		return opt.ChannelValue(s).Name
+		if resolved != nil && resolved.Channels[id] != nil {
+			return resolved.Channels[id].Name
+		}
+		return id
 	case dg.ApplicationCommandOptionRole:
-		return opt.RoleValue(s, guildID).Name
+		if resolved != nil && resolved.Roles[id] != nil {
+			return resolved.Roles[id].Name
+		}
+		return id
 	case dg.ApplicationCommandOptionNumber:
 		return fmt.Sprintf("%.2f", opt.FloatValue())
 	default:
@@ -232,15 +261,38 @@ func formatCommandValue(s *dg.Session, guildID string, opt *dg.ApplicationComman
 	}
 }
 
-func formatCommandOption(s *dg.Session, guildID string, opt *dg.ApplicationCommandInteractionDataOption) string {
+func formatCommandOption(resolved *dg.ApplicationCommandInteractionDataResolved, opt *dg.ApplicationCommandInteractionDataOption) string {
 	switch opt.Type {
 	case dg.ApplicationCommandOptionSubCommand, dg.ApplicationCommandOptionSubCommandGroup:
 		subParts := []string{opt.Name}
 		for _, subOpt := range opt.Options {
-			subParts = append(subParts, formatCommandOption(s, guildID, subOpt))
+			subParts = append(subParts, formatCommandOption(resolved, subOpt))
 		}
 		return strings.Join(subParts, " ")
 	default:
-		return fmt.Sprintf("%s:%v", opt.Name, formatCommandValue(s, guildID, opt))
+		return fmt.Sprintf("%s:%v", opt.Name, formatCommandValue(resolved, opt))
 	}
 }
+
+var barEighths = []string{"", "▏", "▎", "▍", "▌", "▋", "▊", "▉"}
+
+func FormatBar(value, max, width int) string {
+	if value <= 0 || max <= 0 || width <= 0 {
+		return ""
+	}
+
+	eighths := min(value*width*8/max, width*8)
+	if eighths == 0 {
+		eighths = 1
+	}
+
+	return strings.Repeat("█", eighths/8) + barEighths[eighths%8]
+}
+
+func FormatPercent(n, total int) string {
+	if total == 0 {
+		return "0%"
+	}
+
+	return fmt.Sprintf("%.1f%%", float64(n)*100/float64(total))
+}
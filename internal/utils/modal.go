@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	dg "github.com/bwmarrin/discordgo"
+)
+
+const modalTag = "modal"
+
+func ModalValues(data dg.ModalSubmitInteractionData) map[string]string {
+	values := make(map[string]string)
+	collectModalValues(values, data.Components)
+	return values
+}
+
+func collectModalValues(values map[string]string, components []dg.MessageComponent) {
+	for _, c := range components {
+		switch v := c.(type) {
+		case *dg.ActionsRow:
+			collectModalValues(values, v.Components)
+		case dg.ActionsRow:
+			collectModalValues(values, v.Components)
+		case *dg.TextInput:
+			values[v.CustomID] = v.Value
+		case dg.TextInput:
+			values[v.CustomID] = v.Value
+		}
+	}
+}
+
+func DecodeModal(data dg.ModalSubmitInteractionData, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("decode target must be a non-nil struct pointer, got %T", v)
+	}
+
+	values := ModalValues(data)
+	rv = rv.Elem()
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get(modalTag)
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		// A field the decoder can't fill is a bug in the handler, not bad
+		// input, so it fails the same way whatever the user submitted.
+		if !supportedModalField(field.Type) {
+			return fmt.Errorf("unsupported modal field %s of type %s", field.Name, field.Type)
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		raw, ok := values[name]
+		if !ok || raw == "" {
+			if opts == "required" {
+				return Failure{
+					Type:    ErrBadInput,
+					Message: fmt.Sprintf("The `%s` field is required", name),
+				}
+			}
+			continue
+		}
+
+		if err := setModalField(rv.Field(i), raw); err != nil {
+			return Failure{
+				Type:    ErrBadInput,
+				Message: fmt.Sprintf("The `%s` field is invalid", name),
+				Data:    map[string]any{"value": raw, "error": err},
+			}
+		}
+	}
+
+	return nil
+}
+
+func supportedModalField(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func setModalField(f reflect.Value, raw string) error {
+	if f.Kind() == reflect.Pointer {
+		elem := reflect.New(f.Type().Elem())
+		if err := setModalField(elem.Elem(), raw); err != nil {
+			return err
+		}
+		f.Set(elem)
+		return nil
+	}
+
+	raw = strings.TrimSpace(raw)
+
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(raw, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported field type %s", f.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+
+	dg "github.com/bwmarrin/discordgo"
+)
+
+func modalData(values map[string]string) dg.ModalSubmitInteractionData {
+	var rows []dg.MessageComponent
+	for id, value := range values {
+		rows = append(rows, &dg.ActionsRow{Components: []dg.MessageComponent{&dg.TextInput{CustomID: id, Value: value}}})
+	}
+	return dg.ModalSubmitInteractionData{CustomID: "test", Components: rows}
+}
+
+type modalForm struct {
+	Name     string  `modal:"name,required"`
+	Count    int     `modal:"count"`
+	Size     uint8   `modal:"size"`
+	Ratio    float64 `modal:"ratio"`
+	Enabled  bool    `modal:"enabled"`
+	Note     *string `modal:"note"`
+	Limit    *int    `modal:"limit"`
+	Skipped  string  `modal:"-"`
+	Untagged string
+	hidden   string   `modal:"hidden"`
+	Ignored  []string `modal:"-"`
+}
+
+func TestDecodeModal(t *testing.T) {
+	note, limit := "hello", 5
+
+	tests := []struct {
+		name    string
+		values  map[string]string
+		want    modalForm
+		failure ErrorType
+		wantErr bool
+	}{
+		{
+			name:   "all fields",
+			values: map[string]string{"name": " recast ", "count": "-3", "size": "200", "ratio": "0.5", "enabled": "true", "note": "hello", "limit": "5"},
+			want:   modalForm{Name: "recast", Count: -3, Size: 200, Ratio: 0.5, Enabled: true, Note: &note, Limit: &limit},
+		},
+		{
+			name:   "optional fields left empty",
+			values: map[string]string{"name": "recast", "count": "", "note": ""},
+			want:   modalForm{Name: "recast"},
+		},
+		{
+			name:   "untagged and skipped fields",
+			values: map[string]string{"name": "recast", "Skipped": "x", "Untagged": "x", "hidden": "x"},
+			want:   modalForm{Name: "recast"},
+		},
+		{
+			name:    "missing required field",
+			values:  map[string]string{"count": "1"},
+			failure: ErrBadInput,
+			wantErr: true,
+		},
+		{
+			name:    "empty required field",
+			values:  map[string]string{"name": ""},
+			failure: ErrBadInput,
+			wantErr: true,
+		},
+		{
+			name:    "invalid integer",
+			values:  map[string]string{"name": "recast", "count": "many"},
+			failure: ErrBadInput,
+			wantErr: true,
+		},
+		{
+			name:    "integer overflow",
+			values:  map[string]string{"name": "recast", "size": "300"},
+			failure: ErrBadInput,
+			wantErr: true,
+		},
+		{
+			name:    "invalid bool",
+			values:  map[string]string{"name": "recast", "enabled": "maybe"},
+			failure: ErrBadInput,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got modalForm
+			err := DecodeModal(modalData(tt.values), &got)
+			if tt.wantErr {
+				var f Failure
+				if !errors.As(err, &f) || f.Type != tt.failure {
+					t.Fatalf("DecodeModal() error = %v, want a %s failure", err, tt.failure)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeModal() error = %v", err)
+			}
+
+			if got.Name != tt.want.Name || got.Count != tt.want.Count || got.Size != tt.want.Size ||
+				got.Ratio != tt.want.Ratio || got.Enabled != tt.want.Enabled || got.Skipped != "" || got.Untagged != "" || got.hidden != "" {
+				t.Errorf("DecodeModal() = %+v, want %+v", got, tt.want)
+			}
+			if (got.Note == nil) != (tt.want.Note == nil) || (got.Note != nil && *got.Note != *tt.want.Note) {
+				t.Errorf("DecodeModal() note = %v, want %v", got.Note, tt.want.Note)
+			}
+			if (got.Limit == nil) != (tt.want.Limit == nil) || (got.Limit != nil && *got.Limit != *tt.want.Limit) {
+				t.Errorf("DecodeModal() limit = %v, want %v", got.Limit, tt.want.Limit)
+			}
+		})
+	}
+}
+
+func TestDecodeModalProgrammerErrors(t *testing.T) {
+	type unsupported struct {
+		Tags []string `modal:"tags"`
+	}
+
+	tests := []struct {
+		name   string
+		target any
+	}{
+		{name: "unsupported field type", target: &unsupported{}},
+		{name: "non-pointer target", target: modalForm{}},
+		{name: "nil target", target: (*modalForm)(nil)},
+		{name: "pointer to non-struct", target: new(string)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The field is absent, so an unsupported type must still be
+			// reported rather than skipped.
+			err := DecodeModal(modalData(nil), tt.target)
+			if err == nil {
+				t.Fatal("DecodeModal() error = nil, want an error")
+			}
+
+			var f Failure
+			if errors.As(err, &f) {
+				t.Errorf("DecodeModal() error = %v, want a plain error rather than a %s failure", err, f.Type)
+			}
+		})
+	}
+}
+
+func TestModalValues(t *testing.T) {
+	data := dg.ModalSubmitInteractionData{Components: []dg.MessageComponent{
+		dg.ActionsRow{Components: []dg.MessageComponent{dg.TextInput{CustomID: "a", Value: "1"}}},
+		&dg.ActionsRow{Components: []dg.MessageComponent{&dg.TextInput{CustomID: "b", Value: "2"}}},
+	}}
+
+	got := ModalValues(data)
+	if len(got) != 2 || got["a"] != "1" || got["b"] != "2" {
+		t.Errorf("ModalValues() = %v, want a=1 b=2", got)
+	}
+}
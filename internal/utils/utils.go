@@ -14,7 +14,7 @@ func GenerateID() string {
 }
 
 func MapOptions(i *dg.InteractionCreate) map[string]*dg.ApplicationCommandInteractionDataOption {
-	os := i.ApplicationCommandData().Options
+	_, os := WalkOptions(i.ApplicationCommandData().Options)
 	om := make(map[string]*dg.ApplicationCommandInteractionDataOption, len(os))
 	for _, opt := range os {
 		om[opt.Name] = opt
@@ -22,6 +22,67 @@ func MapOptions(i *dg.InteractionCreate) map[string]*dg.ApplicationCommandIntera
 	return om
 }
 
+func SubcommandPath(i *dg.InteractionCreate) []string {
+	path, _ := WalkOptions(i.ApplicationCommandData().Options)
+	return path
+}
+
+func WalkOptions(opts []*dg.ApplicationCommandInteractionDataOption) ([]string, []*dg.ApplicationCommandInteractionDataOption) {
+	var path []string
+	for len(opts) == 1 {
+		opt := opts[0]
+		if opt.Type != dg.ApplicationCommandOptionSubCommandGroup && opt.Type != dg.ApplicationCommandOptionSubCommand {
+			break
+		}
+		path = append(path, opt.Name)
+		opts = opt.Options
+	}
+	return path, opts
+}
+
+func FocusedOption(opts []*dg.ApplicationCommandInteractionDataOption) *dg.ApplicationCommandInteractionDataOption {
+	for _, opt := range opts {
+		if opt.Focused {
+			return opt
+		}
+		if focused := FocusedOption(opt.Options); focused != nil {
+			return focused
+		}
+	}
+	return nil
+}
+
+func ResolveTarget(data dg.ApplicationCommandInteractionData) (*dg.User, *dg.Member, *dg.Message, bool) {
+	if data.Resolved == nil || data.TargetID == "" {
+		return nil, nil, nil, false
+	}
+
+	switch data.CommandType {
+	case dg.UserApplicationCommand:
+		user, ok := data.Resolved.Users[data.TargetID]
+		if !ok {
+			return nil, nil, nil, false
+		}
+
+		member := data.Resolved.Members[data.TargetID]
+		if member != nil && member.User == nil {
+			member.User = user
+		}
+
+		return user, member, nil, true
+
+	case dg.MessageApplicationCommand:
+		message, ok := data.Resolved.Messages[data.TargetID]
+		if !ok {
+			return nil, nil, nil, false
+		}
+
+		return message.Author, nil, message, true
+	}
+
+	return nil, nil, nil, false
+}
+
 func GetCommit() string {
 	cmd := exec.Command("git", "rev-parse", "HEAD")
 	var out bytes.Buffer
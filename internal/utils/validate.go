@@ -28,6 +28,20 @@ func ValidateCommand(cmd *dg.ApplicationCommand) ValidationResult {
 		IsValid: true,
 	}
 
+	if cmd.Type == dg.UserApplicationCommand || cmd.Type == dg.MessageApplicationCommand {
+		if cmd.Description != "" {
+			result.Command.Description = ""
+			result.WasModified = true
+			result.Errors = append(result.Errors, "Context menu command description was removed")
+		}
+
+		if len(cmd.Options) > 0 {
+			result.Command.Options = nil
+			result.WasModified = true
+			result.Errors = append(result.Errors, "Context menu command options were removed")
+		}
+	}
+
 	if len(cmd.Name) > maxCommandNameLength {
 		result.Command.Name = cmd.Name[:maxCommandNameLength]
 		result.WasModified = true
@@ -84,3 +98,10 @@ func ValidateCommand(cmd *dg.ApplicationCommand) ValidationResult {
 
 	return result
 }
+
+func CapChoices(choices []*dg.ApplicationCommandOptionChoice) []*dg.ApplicationCommandOptionChoice {
+	if len(choices) > maxChoicesPerOption {
+		return choices[:maxChoicesPerOption]
+	}
+	return choices
+}
@@ -0,0 +1,6 @@
+CREATE TABLE bot (
+    id text PRIMARY KEY,
+    settings jsonb NOT NULL DEFAULT '{}'::jsonb,
+    created timestamp without time zone NOT NULL,
+    updated timestamp without time zone NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS interactions_created_idx;
+DROP INDEX IF EXISTS interactions_guild_id_user_id_idx;
+DROP INDEX IF EXISTS interactions_guild_id_created_idx;
+DROP INDEX IF EXISTS interactions_id_idx;
+
+ALTER TABLE interactions
+    DROP COLUMN command,
+    DROP COLUMN user_id,
+    DROP COLUMN guild_id,
+    DROP COLUMN id;
@@ -0,0 +1,19 @@
+ALTER TABLE interactions
+    ADD COLUMN id text,
+    ADD COLUMN guild_id text,
+    ADD COLUMN user_id text,
+    ADD COLUMN command text;
+
+UPDATE interactions SET
+    id = interaction->>'id',
+    guild_id = NULLIF(interaction->>'guild_id', ''),
+    user_id = COALESCE(interaction->'member'->'user'->>'id', interaction->'user'->>'id'),
+    command = CASE
+        WHEN (interaction->>'type')::int IN (2, 4) THEN interaction->'data'->>'name'
+        WHEN (interaction->>'type')::int IN (3, 5) THEN split_part(interaction->'data'->>'custom_id', ':', 1)
+    END;
+
+CREATE INDEX interactions_id_idx ON interactions (id);
+CREATE INDEX interactions_guild_id_created_idx ON interactions (guild_id, created);
+CREATE INDEX interactions_guild_id_user_id_idx ON interactions (guild_id, user_id);
+CREATE INDEX interactions_created_idx ON interactions (created);
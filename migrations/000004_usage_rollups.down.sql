@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS guild_usage_daily;
+DROP TABLE IF EXISTS command_usage_daily;
+
+ALTER TABLE interactions DROP COLUMN outcome;
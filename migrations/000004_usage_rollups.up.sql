@@ -0,0 +1,22 @@
+ALTER TABLE interactions ADD COLUMN outcome text;
+
+CREATE TABLE command_usage_daily (
+    guild_id text NOT NULL,
+    day date NOT NULL,
+    command text NOT NULL,
+    uses integer NOT NULL DEFAULT 0,
+    users integer NOT NULL DEFAULT 0,
+    failures integer NOT NULL DEFAULT 0,
+    errors integer NOT NULL DEFAULT 0,
+    PRIMARY KEY (guild_id, day, command)
+);
+
+CREATE TABLE guild_usage_daily (
+    guild_id text NOT NULL,
+    day date NOT NULL,
+    interactions integer NOT NULL DEFAULT 0,
+    users integer NOT NULL DEFAULT 0,
+    PRIMARY KEY (guild_id, day)
+);
+
+CREATE INDEX guild_usage_daily_day_idx ON guild_usage_daily (day);
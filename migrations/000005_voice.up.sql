@@ -0,0 +1,25 @@
+CREATE TABLE voice_events (
+    guild_id text NOT NULL,
+    user_id text NOT NULL,
+    channel_id text,
+    type text NOT NULL,
+    created timestamp without time zone NOT NULL
+);
+
+CREATE INDEX voice_events_guild_id_user_id_created_idx ON voice_events (guild_id, user_id, created);
+
+CREATE TABLE voice_sessions (
+    guild_id text NOT NULL,
+    user_id text NOT NULL,
+    channel_id text NOT NULL,
+    muted boolean NOT NULL DEFAULT false,
+    deafened boolean NOT NULL DEFAULT false,
+    ended timestamp without time zone,
+    duration integer,
+    created timestamp without time zone NOT NULL,
+    updated timestamp without time zone NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX voice_sessions_open_idx ON voice_sessions (guild_id, user_id) WHERE ended IS NULL;
+CREATE INDEX voice_sessions_guild_id_created_idx ON voice_sessions (guild_id, created);
+CREATE INDEX voice_sessions_guild_id_user_id_created_idx ON voice_sessions (guild_id, user_id, created);
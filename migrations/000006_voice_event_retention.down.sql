@@ -0,0 +1 @@
+DROP INDEX IF EXISTS voice_events_created_idx;
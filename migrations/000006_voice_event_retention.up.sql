@@ -0,0 +1 @@
+CREATE INDEX voice_events_created_idx ON voice_events (created);
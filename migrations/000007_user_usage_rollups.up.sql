@@ -0,0 +1,13 @@
+CREATE TABLE user_usage_daily (
+    guild_id text NOT NULL,
+    day date NOT NULL,
+    user_id text NOT NULL,
+    interactions integer NOT NULL DEFAULT 0,
+    PRIMARY KEY (guild_id, day, user_id)
+);
+
+INSERT INTO user_usage_daily (guild_id, day, user_id, interactions)
+SELECT guild_id, created::date, user_id, COUNT(*)
+FROM interactions
+WHERE guild_id IS NOT NULL AND user_id IS NOT NULL
+GROUP BY guild_id, created::date, user_id;